@@ -0,0 +1,71 @@
+// Command import loads tasks from a CSV or JSON file into the database.
+//
+//	go run ./cmd/import -file tasks.csv -tz Asia/Kolkata -dry-run
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/config"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	file := flag.String("file", "", "CSV or JSON file to import (required)")
+	format := flag.String("format", "", "csv or json (default: from file extension)")
+	tz := flag.String("tz", "UTC", "timezone for due dates without an offset")
+	dryRun := flag.Bool("dry-run", false, "validate only, do not write tasks")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatalf("timezone: %v", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	recs, err := service.ParseImport(f, *format)
+	if err != nil {
+		log.Fatalf("parse: %v", err)
+	}
+
+	config.LoadEnv()
+	db, err := gorm.Open(postgres.Open(config.DSN()), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	taskSvc := service.NewTaskService(repository.NewGormRepo(db))
+
+	report, err := taskSvc.Import(recs, loc, *dryRun, service.NewCorrelationID())
+	if err != nil {
+		log.Fatalf("import: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+	if report.Rejected > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -31,21 +32,64 @@ func main() {
 	}
 
 	// Automigrate
-	if err := db.AutoMigrate(&models.Task{}, &models.ReminderRule{}, &models.AuditLog{}, &models.ReminderExecution{}); err != nil {
+	if err := db.AutoMigrate(models.Tables()...); err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
 
 	// Repos
 	repo := repository.NewGormRepo(db)
+	if err := repo.MigrateLegacyStatuses(); err != nil {
+		log.Fatalf("migrate statuses: %v", err)
+	}
+	if err := repo.EnsureSearchIndexes(); err != nil {
+		log.Fatalf("create search indexes: %v", err)
+	}
 
 	// Services
+	broker := service.NewEventBroker(repo)
+	repo.OnAudit(broker.Wake)
 	reminderSvc := service.NewReminderService(repo)
 	taskSvc := service.NewTaskService(repo)
+	socketSvc := service.NewSocketService(repo, taskSvc)
+	dispatcher := service.NewDispatcher(repo, service.LogNotifier{}, service.NewWebhookNotifier(repo), socketSvc)
+	dispatcher.SetRetryPolicy(service.WebhookChannel, service.RetryPolicy{MaxAttempts: 8, BaseDelay: time.Minute, MaxDelay: 6 * time.Hour})
+	// offline users get their reminders when they connect, so keep retrying for hours
+	dispatcher.SetRetryPolicy(service.SocketChannel, service.RetryPolicy{MaxAttempts: 12, BaseDelay: time.Minute, MaxDelay: 4 * time.Hour})
+	recipientRate := config.RateLimitPerMinute("RATE_LIMIT_RECIPIENT_PER_MIN", 10)
+	channelRate := config.RateLimitPerMinute("RATE_LIMIT_CHANNEL_PER_MIN", 300)
+	globalRate := config.RateLimitPerMinute("RATE_LIMIT_GLOBAL_PER_MIN", 600)
+	dispatcher.SetRateLimits(service.RateLimits{
+		Global:       service.Limit{PerMinute: globalRate, Burst: int(globalRate)},
+		Channel:      service.Limit{PerMinute: channelRate, Burst: int(channelRate)},
+		Recipient:    service.Limit{PerMinute: recipientRate, Burst: int(recipientRate)},
+		Overflow:     config.RateLimitOverflow(),
+		DigestWindow: 15 * time.Minute,
+	})
+	reminderSvc.UseDispatcher(dispatcher)
+	reminderSvc.AddChannel(service.SocketChannel)
+	actionLinks := service.NewActionLinks(config.ActionLinkSecret(), config.PublicURL())
+	reminderSvc.UseActionLinks(actionLinks)
+	socketSvc.UseDispatcher(dispatcher)
+	webhookSvc := service.NewWebhookService(repo, dispatcher)
+	reminderSvc.UseWebhooks(webhookSvc)
+	taskSvc.AddObserver(reminderSvc)
+	taskSvc.OnComplete(webhookSvc)
+	calendarSvc := service.NewCalendarService(repo, taskSvc)
+	searchSvc := service.NewSearchService(repo)
 
 	// Handlers
-	reminderHandler := handler.NewReminderHandler(repo)
+	reminderHandler := handler.NewReminderHandler(reminderSvc, webhookSvc, repo)
 	auditHandler := handler.NewAuditHandler(repo)
-	taskHandler := handler.NewTaskHandler(taskSvc, repo)
+	taskHandler := handler.NewTaskHandler(taskSvc, reminderSvc, repo)
+	calendarHandler := handler.NewCalendarHandler(calendarSvc, repo)
+	deliveryHandler := handler.NewDeliveryHandler(dispatcher, repo)
+	webhookHandler := handler.NewWebhookHandler(webhookSvc, repo)
+	digestHandler := handler.NewDigestHandler(repo)
+	eventsHandler := handler.NewEventsHandler(broker)
+	socketHandler := handler.NewSocketHandler(socketSvc, repo)
+	actionHandler := handler.NewActionHandler(actionLinks, taskSvc, repo)
+	tagHandler := handler.NewTagHandler(taskSvc, repo)
+	searchHandler := handler.NewSearchHandler(searchSvc, repo)
 
 	// Router
 	r := chi.NewRouter()
@@ -54,18 +98,34 @@ func main() {
 	reminderHandler.Register(r)
 	auditHandler.Register(r)
 	taskHandler.Register(r)
+	calendarHandler.Register(r)
+	deliveryHandler.Register(r)
+	webhookHandler.Register(r)
+	digestHandler.Register(r)
+	eventsHandler.Register(r)
+	socketHandler.Register(r)
+	actionHandler.Register(r)
+	tagHandler.Register(r)
+	searchHandler.Register(r)
 
 	// Seed sample tasks & rules
 	seedIfEmpty(repo)
 
 	// Scheduler
 	ctx, cancel := context.WithCancel(context.Background())
-	go reminderSvc.StartScheduler(ctx, 60*time.Second)
+	go reminderSvc.StartScheduler(ctx, 10*time.Minute)
+	go dispatcher.Start(ctx, 10*time.Second)
+	go broker.Run(ctx, 2*time.Second)
 
 	// Serve UI static files
 	r.Handle("/*", http.FileServer(http.Dir("./ui")))
 
-	server := &http.Server{Addr: ":" + config.HTTPPort(), Handler: r}
+	server := &http.Server{
+		Addr:    ":" + config.HTTPPort(),
+		Handler: r,
+		// request contexts end on shutdown, so open event streams and sockets close
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 
 	// Graceful shutdown
 	go func() {
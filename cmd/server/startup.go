@@ -16,11 +16,11 @@ func seedIfEmpty(repo *repository.GormRepo) {
 
 	now := time.Now()
 	tasks := []models.Task{
-		{Title: "Pay electricity bill", Description: "Electricity", DueAt: now.Add(2 * time.Minute), Status: "pending"},
-		{Title: "Submit assignment", Description: "Bootcamp", DueAt: now.Add(10 * time.Minute), Status: "pending"},
-		{Title: "Daily workout", Description: "Run", DueAt: now.Add(1 * time.Hour), Status: "pending"},
-		{Title: "Call supplier", Description: "Discuss order", DueAt: now.Add(3 * time.Minute), Status: "pending"},
-		{Title: "Read chapter 4", Description: "Study", DueAt: now.Add(20 * time.Minute), Status: "pending"},
+		{Title: "Pay electricity bill", Description: "Electricity", DueAt: now.Add(2 * time.Minute), Status: models.StatusTodo},
+		{Title: "Submit assignment", Description: "Bootcamp", DueAt: now.Add(10 * time.Minute), Status: models.StatusTodo},
+		{Title: "Daily workout", Description: "Run", DueAt: now.Add(1 * time.Hour), Status: models.StatusTodo},
+		{Title: "Call supplier", Description: "Discuss order", DueAt: now.Add(3 * time.Minute), Status: models.StatusTodo},
+		{Title: "Read chapter 4", Description: "Study", DueAt: now.Add(20 * time.Minute), Status: models.StatusTodo},
 	}
 	for i := range tasks {
 		_ = repo.CreateTask(&tasks[i])
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -32,3 +33,46 @@ func HTTPPort() string {
 	}
 	return v
 }
+
+// RateLimitPerMinute returns a notification rate limit from the environment
+// (e.g. RATE_LIMIT_RECIPIENT_PER_MIN); 0 disables the limit
+func RateLimitPerMinute(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		log.Fatalf("%s must be a non-negative number", key)
+	}
+	return f
+}
+
+// RateLimitOverflow returns what happens to rate-limited reminders: "defer"
+// (default) or "digest"
+func RateLimitOverflow() string {
+	v := os.Getenv("RATE_LIMIT_OVERFLOW")
+	if v == "" {
+		return "defer"
+	}
+	if v != "defer" && v != "digest" {
+		log.Fatal("RATE_LIMIT_OVERFLOW must be defer or digest")
+	}
+	return v
+}
+
+// ActionLinkSecret returns the key reminder action links are signed with;
+// empty means a random key per process
+func ActionLinkSecret() string {
+	return os.Getenv("ACTION_LINK_SECRET")
+}
+
+// PublicURL returns the base URL links in reminders point to (default
+// http://localhost:<port>)
+func PublicURL() string {
+	v := os.Getenv("PUBLIC_URL")
+	if v == "" {
+		return "http://localhost:" + HTTPPort()
+	}
+	return v
+}
@@ -2,7 +2,10 @@ module github.com/Nehyan9895/reminder-system
 
 go 1.25.0
 
-require gorm.io/gorm v1.31.0
+require (
+	gorm.io/driver/postgres v1.6.0
+	gorm.io/gorm v1.31.0
+)
 
 require (
 	github.com/bytedance/sonic v1.14.0 // indirect
@@ -45,7 +48,6 @@ require (
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/tools v0.34.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
-	gorm.io/driver/postgres v1.6.0 // indirect
 	modernc.org/libc v1.66.3 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// actionPage is shown for reminder links: a confirmation form on GET (so mail
+// scanners that prefetch links change nothing) and the result on POST
+var actionPage = template.Must(template.New("action").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Reminder</title></head>
+<body style="font-family: sans-serif; max-width: 32rem; margin: 3rem auto">
+{{if .Error}}<p>{{.Error}}</p>
+{{else if .Done}}<p>{{.Done}}</p>
+{{else}}<p>{{.Question}}</p>
+<form method="post"><button type="submit">{{.Button}}</button></form>
+{{end}}</body></html>
+`))
+
+type actionPageData struct {
+	Question, Button string
+	Done             string
+	Error            string
+}
+
+type ActionHandler struct {
+	links *service.ActionLinks
+	tasks *service.TaskService
+	Repo  *repository.GormRepo
+}
+
+func NewActionHandler(links *service.ActionLinks, tasks *service.TaskService, repo *repository.GormRepo) *ActionHandler {
+	return &ActionHandler{links: links, tasks: tasks, Repo: repo}
+}
+
+// Register all Action endpoints
+func (h *ActionHandler) Register(r chi.Router) {
+	r.Get("/actions/{token}", h.Confirm)
+	r.Post("/actions/{token}", h.Perform)
+}
+
+func writeActionPage(w http.ResponseWriter, code int, data actionPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = actionPage.Execute(w, data)
+}
+
+// load verifies the link and returns its claims and task; on failure the
+// error page has been written
+func (h *ActionHandler) load(w http.ResponseWriter, r *http.Request) (*service.ActionClaims, *models.Task, bool) {
+	c, err := h.links.Verify(chi.URLParam(r, "token"), time.Now())
+	switch {
+	case errors.Is(err, service.ErrActionExpired):
+		writeActionPage(w, http.StatusGone, actionPageData{Error: "This link has expired."})
+		return nil, nil, false
+	case err != nil:
+		writeActionPage(w, http.StatusNotFound, actionPageData{Error: "This link is not valid."})
+		return nil, nil, false
+	}
+	t, err := h.tasks.Get(c.TaskID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeActionPage(w, http.StatusNotFound, actionPageData{Error: "This task no longer exists."})
+		return nil, nil, false
+	}
+	if err != nil {
+		writeActionPage(w, http.StatusInternalServerError, actionPageData{Error: "Something went wrong; try again later."})
+		return nil, nil, false
+	}
+	// the link speaks for the person reminded, not whoever holds the task now
+	if t.Assignee != c.Recipient {
+		writeActionPage(w, http.StatusGone, actionPageData{Error: "This task has been reassigned."})
+		return nil, nil, false
+	}
+	return c, t, true
+}
+
+// Confirm asks before acting
+func (h *ActionHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	c, t, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	data := actionPageData{Question: fmt.Sprintf("Mark %q as done?", t.Title), Button: "Mark done"}
+	if c.Action == service.ActionSnooze {
+		data = actionPageData{Question: fmt.Sprintf("Snooze reminders for %q for %d minutes?", t.Title, c.Minutes), Button: "Snooze"}
+	}
+	writeActionPage(w, http.StatusOK, data)
+}
+
+// Perform completes or snoozes the task on behalf of the reminder's recipient
+func (h *ActionHandler) Perform(w http.ResponseWriter, r *http.Request) {
+	c, t, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	who := c.Recipient
+	if who == "" {
+		who = "unassigned recipient"
+	}
+
+	switch c.Action {
+	case service.ActionDone:
+		if t.Status == models.StatusDone {
+			writeActionPage(w, http.StatusOK, actionPageData{Done: fmt.Sprintf("%q is already done.", t.Title)})
+			return
+		}
+		task, from, err := h.tasks.TransitionAs(t.ID, models.StatusDone, 0, who+" via reminder link")
+		if errors.Is(err, service.ErrInvalidTransition) {
+			writeActionPage(w, http.StatusConflict, actionPageData{Error: fmt.Sprintf("%q is %s and cannot be marked done.", t.Title, t.Status)})
+			return
+		}
+		if errors.Is(err, service.ErrOpenSubtasks) {
+			writeActionPage(w, http.StatusConflict, actionPageData{Error: fmt.Sprintf("%q still has open subtasks.", t.Title)})
+			return
+		}
+		if err != nil {
+			writeActionPage(w, http.StatusInternalServerError, actionPageData{Error: "Something went wrong; try again later."})
+			return
+		}
+		_ = h.Repo.WriteTaskAudit(task.ID, "task.transition", fmt.Sprintf("%s (%s -> %s) by %s via reminder link", task.Title, from, task.Status, who))
+		writeActionPage(w, http.StatusOK, actionPageData{Done: fmt.Sprintf("%q is marked done.", task.Title)})
+	case service.ActionSnooze:
+		task, err := h.tasks.Snooze(t.ID, time.Now().Add(time.Duration(c.Minutes)*time.Minute))
+		if err != nil {
+			writeActionPage(w, http.StatusInternalServerError, actionPageData{Error: "Something went wrong; try again later."})
+			return
+		}
+		_ = h.Repo.WriteTaskAudit(task.ID, "task.snooze", fmt.Sprintf("%s until %s by %s via reminder link", task.Title, task.SnoozedUntil.Format(time.RFC3339), who))
+		writeActionPage(w, http.StatusOK, actionPageData{Done: fmt.Sprintf("Reminders for %q are snoozed until %s.", task.Title, task.SnoozedUntil.Format("15:04 MST"))})
+	}
+}
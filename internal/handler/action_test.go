@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestActionLinks(t *testing.T) {
+	s := newTestServer(t)
+	tasks := service.NewTaskService(s.repo)
+	newTask := func(title string) *models.Task {
+		task := &models.Task{Title: title, Assignee: "ann", DueAt: time.Now().Add(time.Hour)}
+		if err := tasks.Create(task); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+
+	done := newTask("done")
+	links := s.links.ForReminder(done, time.Now())
+	res, body := s.do(t, "GET", links.Done, "")
+	expect(t, res, body, http.StatusOK)
+	if got, _ := tasks.Get(done.ID); got.Status == models.StatusDone {
+		t.Fatal("GET changed the task")
+	}
+	res, body = s.do(t, "POST", links.Done, "")
+	expect(t, res, body, http.StatusOK)
+	if got, _ := tasks.Get(done.ID); got.Status != models.StatusDone {
+		t.Errorf("status after done link = %q", got.Status)
+	}
+
+	snoozed := newTask("snoozed")
+	res, body = s.do(t, "POST", s.links.ForReminder(snoozed, time.Now()).Snooze, "")
+	expect(t, res, body, http.StatusOK)
+	if got, _ := tasks.Get(snoozed.ID); got.SnoozedUntil == nil || got.SnoozedUntil.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("snoozed until %v, want about an hour from now", got.SnoozedUntil)
+	}
+
+	expired := s.links.ForReminder(newTask("expired"), time.Now().Add(-service.ActionLinkTTL))
+	res, body = s.do(t, "POST", expired.Done, "")
+	expect(t, res, body, http.StatusGone)
+
+	res, body = s.do(t, "POST", links.Done+"x", "")
+	expect(t, res, body, http.StatusNotFound)
+
+	reassigned := newTask("reassigned")
+	links = s.links.ForReminder(reassigned, time.Now())
+	reassigned.Assignee = "bob"
+	if err := tasks.Update(reassigned); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"GET", "POST"} {
+		res, body = s.do(t, method, links.Done, "")
+		expect(t, res, body, http.StatusGone)
+	}
+	if got, _ := tasks.Get(reassigned.ID); got.Status == models.StatusDone {
+		t.Error("a link issued to the previous assignee completed the task")
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/Nehyan9895/reminder-system/internal/models"
 	"github.com/Nehyan9895/reminder-system/internal/repository"
@@ -24,7 +25,14 @@ func (h *AuditHandler) Register(r chi.Router) {
 
 func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 	var logs []models.AuditLog
-	if err := h.Repo.DB.Order("created_at desc").Limit(200).Find(&logs).Error; err != nil {
+	q := h.Repo.DB.Order("created_at desc").Limit(200)
+	if cid := r.URL.Query().Get("correlation_id"); cid != "" {
+		q = q.Where("correlation_id = ?", cid)
+	}
+	if tid, err := strconv.Atoi(r.URL.Query().Get("task_id")); err == nil {
+		q = q.Where("task_id = ?", tid)
+	}
+	if err := q.Find(&logs).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
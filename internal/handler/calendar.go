@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type CalendarHandler struct {
+	svc  *service.CalendarService
+	Repo *repository.GormRepo
+}
+
+func NewCalendarHandler(svc *service.CalendarService, repo *repository.GormRepo) *CalendarHandler {
+	return &CalendarHandler{svc: svc, Repo: repo}
+}
+
+// Register all Calendar endpoints
+func (h *CalendarHandler) Register(r chi.Router) {
+	r.Get("/calendar.ics", h.Feed)
+	r.Route("/calendar", func(r chi.Router) {
+		r.Post("/feeds", h.CreateFeed)
+		r.Get("/feeds", h.ListFeeds)
+		r.Delete("/feeds/{id}", h.DeleteFeed)
+		r.Post("/import", h.Import)
+	})
+}
+
+type createFeedRequest struct {
+	Owner string `json:"owner"`
+}
+
+type createFeedResponse struct {
+	ID    uint   `json:"id"`
+	Owner string `json:"owner"`
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// CreateFeed issues a secret feed URL; the token is only shown once
+func (h *CalendarHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	var in createFeedRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.Owner = strings.TrimSpace(in.Owner)
+	if in.Owner == "" {
+		http.Error(w, "owner is required", http.StatusBadRequest)
+		return
+	}
+
+	feed, token, err := h.svc.CreateFeed(in.Owner)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("calendar.feed.create", fmt.Sprintf("feed #%d for %s", feed.ID, feed.Owner))
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createFeedResponse{
+		ID:    feed.ID,
+		Owner: feed.Owner,
+		Token: token,
+		URL:   fmt.Sprintf("%s://%s/calendar.ics?token=%s", scheme, r.Host, token),
+	})
+}
+
+func (h *CalendarHandler) ListFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.Repo.ListCalendarFeeds()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(feeds)
+}
+
+// DeleteFeed revokes a feed URL
+func (h *CalendarHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if err := h.Repo.DeleteCalendarFeed(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("calendar.feed.delete", strconv.Itoa(id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed serves open tasks as iCalendar. Query: token (required),
+// component=todo|event (default todo; many calendar apps only show events).
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	feed, err := h.svc.FeedByToken(token)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	asEvents := q.Get("component") == "event"
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if err := h.svc.WriteFeed(w, feed, asEvents); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Import creates tasks from the VEVENT/VTODO entries of an .ics body.
+// Query: dry_run=true to only validate.
+func (h *CalendarHandler) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = service.NewCorrelationID()
+	}
+
+	report, err := h.svc.ImportICS(http.MaxBytesReader(w, r.Body, maxImportBytes), dryRun, correlationID)
+	if errors.Is(err, service.ErrInvalidCalendar) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
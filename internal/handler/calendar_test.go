@@ -0,0 +1,26 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCalendarImportStatus(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.repo.DB.Exec(`CREATE TRIGGER refuse_boom BEFORE INSERT ON tasks WHEN NEW.title = 'boom'
+		BEGIN SELECT RAISE(ABORT, 'refused'); END`).Error; err != nil {
+		t.Fatal(err)
+	}
+	ics := func(summary string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:" + summary + "\r\nDUE:20300101T090000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	}
+
+	res, body := s.do(t, "POST", "/calendar/import", "not a calendar")
+	expect(t, res, body, http.StatusBadRequest)
+
+	res, body = s.do(t, "POST", "/calendar/import", ics("boom"))
+	expect(t, res, body, http.StatusInternalServerError)
+
+	res, body = s.do(t, "POST", "/calendar/import", ics("fine"))
+	expect(t, res, body, http.StatusOK)
+}
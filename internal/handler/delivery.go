@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type DeliveryHandler struct {
+	svc  *service.Dispatcher
+	Repo *repository.GormRepo
+}
+
+func NewDeliveryHandler(svc *service.Dispatcher, repo *repository.GormRepo) *DeliveryHandler {
+	return &DeliveryHandler{svc: svc, Repo: repo}
+}
+
+// Register all Delivery endpoints
+func (h *DeliveryHandler) Register(r chi.Router) {
+	r.Route("/deliveries", func(r chi.Router) {
+		r.Get("/dead", h.Dead)
+		r.Get("/rate-limits", h.RateLimits)
+		r.Post("/{id}/retry", h.Retry)
+	})
+}
+
+type deadLettersResponse struct {
+	Total       int64               `json:"total"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+	DeadLetters []models.DeadLetter `json:"dead_letters"`
+}
+
+// Dead lists permanently failed deliveries. Query: limit (default 50, max 500), offset.
+func (h *DeliveryHandler) Dead(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	offset = max(offset, 0)
+
+	dead, total, err := h.Repo.ListDeadLetters(limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dead == nil {
+		dead = []models.DeadLetter{}
+	}
+	json.NewEncoder(w).Encode(deadLettersResponse{Total: total, Limit: limit, Offset: offset, DeadLetters: dead})
+}
+
+// Retry requeues a dead-lettered notification (by notification id) with a
+// fresh attempt budget
+func (h *DeliveryHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	dl, err := h.svc.Retry(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "no dead delivery with that id", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("delivery.retry", fmt.Sprintf("notification #%d on %s after %d attempts (%s)",
+		dl.NotificationID, dl.Channel, dl.Attempts, dl.LastError))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"notification_id": dl.NotificationID, "status": models.NotificationPending})
+}
+
+type rateLimitsResponse struct {
+	service.RateLimits
+	DigestWindowMinutes int                    `json:"digest_window_minutes"`
+	Stats               service.RateLimitStats `json:"stats"`
+}
+
+// RateLimits shows the configured send limits and how often they kicked in
+// since this process started
+func (h *DeliveryHandler) RateLimits(w http.ResponseWriter, r *http.Request) {
+	limits, stats := h.svc.RateLimitStatus()
+	json.NewEncoder(w).Encode(rateLimitsResponse{
+		RateLimits:          limits,
+		DigestWindowMinutes: int(limits.DigestWindow.Minutes()),
+		Stats:               stats,
+	})
+}
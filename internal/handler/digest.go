@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type DigestHandler struct {
+	Repo *repository.GormRepo
+}
+
+func NewDigestHandler(r *repository.GormRepo) *DigestHandler {
+	return &DigestHandler{Repo: r}
+}
+
+// Register all Digest endpoints
+func (h *DigestHandler) Register(r chi.Router) {
+	r.Route("/digests", func(r chi.Router) {
+		r.Get("/", h.List)
+		r.Put("/", h.Put)
+		r.Delete("/{id}", h.Delete)
+	})
+}
+
+func (h *DigestHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Repo.ListDigestSettings()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// Put turns on (or changes) the digest of one recipient on one channel.
+// Recipient is a task assignee, "" for unassigned tasks or "*" for everyone
+// without a setting of their own.
+func (h *DigestHandler) Put(w http.ResponseWriter, r *http.Request) {
+	var in models.DigestSetting
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.ID = 0
+	in.Recipient = strings.TrimSpace(in.Recipient)
+	in.Channel = strings.TrimSpace(in.Channel)
+	if in.Channel == "" {
+		in.Channel = service.DefaultChannel
+	}
+	if in.Channel == service.WebhookChannel {
+		http.Error(w, "webhook deliveries cannot be digested", http.StatusBadRequest)
+		return
+	}
+	maxMinutes := int(service.MaxDigestWindow.Minutes())
+	if in.WindowMinutes < 1 || in.WindowMinutes > maxMinutes {
+		http.Error(w, fmt.Sprintf("window_minutes must be between 1 and %d", maxMinutes), http.StatusBadRequest)
+		return
+	}
+	in.UpdatedAt = time.Now()
+
+	if err := h.Repo.UpsertDigestSetting(&in); err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("digest.set", fmt.Sprintf("%q on %s every %d minutes", in.Recipient, in.Channel, in.WindowMinutes))
+	json.NewEncoder(w).Encode(in)
+}
+
+// Delete turns a digest off; reminders already held are still sent when
+// their window closes
+func (h *DigestHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if err := h.Repo.DeleteDigestSetting(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("digest.delete", strconv.Itoa(id))
+	w.WriteHeader(http.StatusNoContent)
+}
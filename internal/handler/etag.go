@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag formats a row version as a strong entity tag
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// setETag writes the ETag header for a row version
+func setETag(w http.ResponseWriter, version uint) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// notModified handles If-None-Match on GET; it reports true when a 304 was written
+func notModified(w http.ResponseWriter, r *http.Request, version uint) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch compares the If-Match header against the current row version
+// and returns the version the write must be conditioned on. A missing header
+// is rejected with 428 when required, otherwise the current version is used.
+// "*" matches any current version.
+func checkIfMatch(r *http.Request, current uint, required bool) (uint, int, error) {
+	im := strings.TrimSpace(r.Header.Get("If-Match"))
+	if im == "" {
+		if required {
+			return 0, http.StatusPreconditionRequired, fmt.Errorf("If-Match header is required")
+		}
+		return current, 0, nil
+	}
+	if im == "*" {
+		return current, 0, nil
+	}
+	for _, tag := range strings.Split(im, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			// weak tags never match for If-Match (RFC 9110 13.1.1)
+			continue
+		}
+		if tag == etag(current) {
+			return current, 0, nil
+		}
+	}
+	return 0, http.StatusPreconditionFailed, fmt.Errorf("resource has changed (current version %d)", current)
+}
@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTaskIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	res, body := s.do(t, "POST", "/tasks", `{"title": "Pay rent", "due_at": "2030-01-01T09:00:00Z"}`)
+	expect(t, res, body, http.StatusOK)
+	if got := res.Header.Get("ETag"); got != `"1"` {
+		t.Fatalf("ETag after create = %s, want \"1\"", got)
+	}
+	var task struct {
+		ID      uint `json:"id"`
+		Version uint `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		t.Fatal(err)
+	}
+	path := "/tasks/" + itoa(task.ID)
+	update := `{"title": "Pay rent today", "due_at": "2030-01-01T09:00:00Z", "status": "todo"}`
+
+	res, body = s.do(t, "PUT", path, update)
+	expect(t, res, body, http.StatusPreconditionRequired)
+	res, body = s.do(t, "PUT", path, update, "If-Match", `"7"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+	res, body = s.do(t, "PUT", path, update, "If-Match", `W/"1"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+
+	res, body = s.do(t, "PUT", path, update, "If-Match", `"1"`)
+	expect(t, res, body, http.StatusOK)
+	if got := res.Header.Get("ETag"); got != `"2"` {
+		t.Errorf("ETag after update = %s, want \"2\"", got)
+	}
+	if err := json.Unmarshal([]byte(body), &task); err != nil || task.Version != 2 {
+		t.Errorf("version after update = %d, want 2", task.Version)
+	}
+
+	// the old version no longer matches
+	res, body = s.do(t, "PATCH", path, `{"title": "stale"}`, "If-Match", `"1"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+	res, body = s.do(t, "PATCH", path, `{"title": "Pay rent now"}`, "If-Match", `"2"`)
+	expect(t, res, body, http.StatusOK)
+	if got := res.Header.Get("ETag"); got != `"3"` {
+		t.Errorf("ETag after patch = %s, want \"3\"", got)
+	}
+
+	res, _ = s.do(t, "GET", path, "", "If-None-Match", `"3"`)
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("GET with current If-None-Match: got %d, want 304", res.StatusCode)
+	}
+
+	res, body = s.do(t, "DELETE", path, "")
+	expect(t, res, body, http.StatusPreconditionRequired)
+	res, body = s.do(t, "DELETE", path, "", "If-Match", `"2"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+	res, body = s.do(t, "DELETE", path, "", "If-Match", `"3"`)
+	expect(t, res, body, http.StatusNoContent)
+	res, body = s.do(t, "GET", path, "")
+	expect(t, res, body, http.StatusNotFound)
+}
+
+func TestRuleIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	rule := `{"name": "5 min before", "active": true, "rule_type": "before_due", "params": "{\"minutes_before\": 5}"}`
+	res, body := s.do(t, "POST", "/rules", rule)
+	expect(t, res, body, http.StatusOK)
+	var rr struct {
+		ID      uint `json:"id"`
+		Version uint `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(body), &rr); err != nil {
+		t.Fatal(err)
+	}
+	path := "/rules/" + itoa(rr.ID)
+	update := `{"name": "10 min before", "rule_type": "before_due", "params": "{\"minutes_before\": 10}"}`
+
+	res, body = s.do(t, "PUT", path, update)
+	expect(t, res, body, http.StatusPreconditionRequired)
+	res, body = s.do(t, "PUT", path, update, "If-Match", `"2"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+	res, body = s.do(t, "PUT", path, update, "If-Match", `"1"`)
+	expect(t, res, body, http.StatusOK)
+	if got := res.Header.Get("ETag"); got != `"2"` {
+		t.Errorf("ETag after update = %s, want \"2\"", got)
+	}
+	if err := json.Unmarshal([]byte(body), &rr); err != nil || rr.Version != 2 {
+		t.Errorf("version after update = %d, want 2", rr.Version)
+	}
+
+	res, body = s.do(t, "DELETE", path, "")
+	expect(t, res, body, http.StatusPreconditionRequired)
+	res, body = s.do(t, "DELETE", path, "", "If-Match", `"1"`)
+	expect(t, res, body, http.StatusPreconditionFailed)
+	res, body = s.do(t, "DELETE", path, "", "If-Match", "*")
+	expect(t, res, body, http.StatusNoContent)
+}
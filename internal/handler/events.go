@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// sseHeartbeat keeps idle connections open through proxies
+const sseHeartbeat = 15 * time.Second
+
+type EventsHandler struct {
+	broker *service.EventBroker
+}
+
+func NewEventsHandler(broker *service.EventBroker) *EventsHandler {
+	return &EventsHandler{broker: broker}
+}
+
+// Register all Events endpoints
+func (h *EventsHandler) Register(r chi.Router) {
+	r.Get("/events", h.Stream)
+}
+
+// Stream sends audit events as Server-Sent Events. Query: types (comma-
+// separated prefixes such as "reminder.,task."), last_event_id (for clients
+// that cannot send the Last-Event-ID header).
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	resume, _ := strconv.ParseUint(lastID, 10, 64)
+	var prefixes []string
+	for _, p := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	sub, backlog, truncatedAt, err := h.broker.Subscribe(uint(resume), prefixes)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	// id: carries the highest ID sent so far, so a client resumes after
+	// everything it saw even when a late entry arrives out of order
+	cursor := uint(resume)
+	replayed := map[uint]bool{} // backlog IDs; live events may repeat them
+	write := func(e models.AuditLog) error {
+		if replayed[e.ID] {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		cursor = max(cursor, e.ID)
+		// no "event:" field, so EventSource.onmessage sees every type
+		_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", cursor, data)
+		return err
+	}
+	for _, e := range backlog {
+		if write(e) != nil {
+			return
+		}
+		replayed[e.ID] = true
+	}
+	if truncatedAt != 0 {
+		// more was missed than one backlog holds: move the client's cursor
+		// past what was read and let it reconnect for the rest
+		fmt.Fprintf(w, "id: %d\n\n", max(cursor, truncatedAt))
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			if write(e) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
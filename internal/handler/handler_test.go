@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/handler"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// testServer serves the task, rule, calendar, action and socket endpoints
+// over a sqlite database
+type testServer struct {
+	*httptest.Server
+	repo  *repository.GormRepo
+	links *service.ActionLinks // issues links relative to the server root
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	repo := repository.NewGormRepo(repotest.Open(t))
+	reminderSvc := service.NewReminderService(repo)
+	taskSvc := service.NewTaskService(repo)
+	taskSvc.AddObserver(reminderSvc)
+	webhookSvc := service.NewWebhookService(repo, service.NewDispatcher(repo))
+
+	r := chi.NewRouter()
+	handler.NewTaskHandler(taskSvc, reminderSvc, repo).Register(r)
+	handler.NewReminderHandler(reminderSvc, webhookSvc, repo).Register(r)
+	handler.NewCalendarHandler(service.NewCalendarService(repo, taskSvc), repo).Register(r)
+	links := service.NewActionLinks("test secret", "")
+	handler.NewActionHandler(links, taskSvc, repo).Register(r)
+	handler.NewSocketHandler(service.NewSocketService(repo, taskSvc), repo).Register(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, repo: repo, links: links}
+}
+
+// do sends a request with optional headers ("If-Match", `"1"`, ...) and
+// returns the response with its body read
+func (s *testServer) do(t *testing.T, method, path, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	return res, string(b)
+}
+
+// expect fails the test unless res has the wanted status code
+func expect(t *testing.T, res *http.Response, body string, code int) {
+	t.Helper()
+	if res.StatusCode != code {
+		t.Fatalf("%s %s: got %d, want %d: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, code, body)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
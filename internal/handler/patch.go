@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// mergePatchContentType is the media type for JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// readMergePatch reads a JSON Merge Patch document from the request body.
+// Both application/merge-patch+json and application/json are accepted.
+// Patches touching any of the readOnly fields are rejected.
+func readMergePatch(r *http.Request, readOnly ...string) (map[string]any, int, error) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != mergePatchContentType && mt != "application/json") {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be %s", mergePatchContentType)
+		}
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	var patch map[string]any
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("patch must be a JSON object")
+	}
+	for _, f := range readOnly {
+		if _, ok := patch[f]; ok {
+			return nil, http.StatusBadRequest, fmt.Errorf("field %s is read-only", f)
+		}
+	}
+	return patch, 0, nil
+}
+
+// patchFields lists the top-level fields of a patch for audit details
+func patchFields(patch map[string]any) string {
+	fields := make([]string, 0, len(patch))
+	for k := range patch {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
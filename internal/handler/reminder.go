@@ -2,9 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Nehyan9895/reminder-system/internal/models"
 	"github.com/Nehyan9895/reminder-system/internal/repository"
@@ -13,11 +17,13 @@ import (
 )
 
 type ReminderHandler struct {
-	Repo *repository.GormRepo
+	svc      *service.ReminderService
+	webhooks *service.WebhookService
+	Repo     *repository.GormRepo
 }
 
-func NewReminderHandler(r *repository.GormRepo) *ReminderHandler {
-	return &ReminderHandler{Repo: r}
+func NewReminderHandler(svc *service.ReminderService, webhooks *service.WebhookService, r *repository.GormRepo) *ReminderHandler {
+	return &ReminderHandler{svc: svc, webhooks: webhooks, Repo: r}
 }
 
 // Register all Reminder endpoints
@@ -25,79 +31,149 @@ func (h *ReminderHandler) Register(r chi.Router) {
 	r.Route("/rules", func(r chi.Router) {
 		r.Post("/", h.CreateRule)
 		r.Get("/", h.ListRules)
+		r.Post("/preview", h.Preview)
+		r.Post("/render", h.Render)
 		r.Get("/{id}", h.GetRule)
 		r.Put("/{id}", h.UpdateRule)
+		r.Patch("/{id}", h.PatchRule)
 		r.Delete("/{id}", h.DeleteRule)
 		r.Post("/{id}/activate", h.Activate)
 		r.Post("/{id}/deactivate", h.Deactivate)
+		r.Get("/{id}/executions", h.Executions)
+		r.Get("/{id}/stats", h.Stats)
 	})
 }
 
-func (h *ReminderHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
-	var in models.ReminderRule
-	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// validateRule checks remind_statuses, remind_tags and the per-type uniqueness constraints.
+// The rule with id selfID (if any) is ignored so updates can keep their own values.
+func (h *ReminderHandler) validateRule(in *models.ReminderRule, selfID uint) (int, error) {
+	if err := validateRemindStatuses(in); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := validateRemindTags(in); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := service.ValidateTemplates(in); err != nil {
+		return http.StatusBadRequest, err
 	}
 
 	// --- Validation for uniqueness ---
 	var rules []models.ReminderRule
 	if err := h.Repo.DB.Find(&rules).Error; err != nil {
-		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError, fmt.Errorf("db error: %w", err)
 	}
 
-	for _, rr := range rules {
+	for _, existing := range rules {
+		// skip self
+		if selfID != 0 && existing.ID == selfID {
+			continue
+		}
+
 		switch in.RuleType {
 		case "at_due":
-			if rr.RuleType == "at_due" {
-				http.Error(w, "only one at_due rule is allowed", http.StatusBadRequest)
-				return
+			if existing.RuleType == "at_due" {
+				return http.StatusBadRequest, fmt.Errorf("only one at_due rule is allowed")
 			}
 		case "before_due":
 			var newParams service.BeforeDueParams
 			if err := json.Unmarshal([]byte(in.Params), &newParams); err != nil {
-				http.Error(w, "invalid params JSON", http.StatusBadRequest)
-				return
+				return http.StatusBadRequest, fmt.Errorf("invalid params JSON")
 			}
 
-			if rr.RuleType == "before_due" {
+			if existing.RuleType == "before_due" {
 				var existingParams service.BeforeDueParams
-				if err := json.Unmarshal([]byte(rr.Params), &existingParams); err == nil {
+				if err := json.Unmarshal([]byte(existing.Params), &existingParams); err == nil {
 					if existingParams.MinutesBefore == newParams.MinutesBefore {
-						http.Error(w, fmt.Sprintf("before_due rule with %d minutes already exists", newParams.MinutesBefore), http.StatusBadRequest)
-						return
+						return http.StatusBadRequest, fmt.Errorf("before_due rule with %d minutes already exists", newParams.MinutesBefore)
 					}
 				}
 			}
 		case "interval":
 			var newParams service.IntervalParams
 			if err := json.Unmarshal([]byte(in.Params), &newParams); err != nil {
-				http.Error(w, "invalid params JSON", http.StatusBadRequest)
-				return
+				return http.StatusBadRequest, fmt.Errorf("invalid params JSON")
 			}
 
-			if rr.RuleType == "interval" {
+			if existing.RuleType == "interval" {
 				var existingParams service.IntervalParams
-				if err := json.Unmarshal([]byte(rr.Params), &existingParams); err == nil {
+				if err := json.Unmarshal([]byte(existing.Params), &existingParams); err == nil {
 					if existingParams.IntervalMin == newParams.IntervalMin {
-						http.Error(w, fmt.Sprintf("interval rule with %d minutes already exists", newParams.IntervalMin), http.StatusBadRequest)
-						return
+						return http.StatusBadRequest, fmt.Errorf("interval rule with %d minutes already exists", newParams.IntervalMin)
 					}
 				}
 			}
 		}
 	}
+	return 0, nil
+}
+
+func (h *ReminderHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var in models.ReminderRule
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if code, err := h.validateRule(&in, 0); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
 
 	if err := h.Repo.CreateRule(&in); err != nil {
 		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	h.svc.RuleChanged(in.ID)
+	h.webhooks.Publish(service.EventRuleCreated, in)
 	_ = h.Repo.WriteAudit("rule.create", in.Name)
+	setETag(w, in.Version)
 	json.NewEncoder(w).Encode(in)
 }
 
+// writeRuleError maps repository write errors onto HTTP status codes
+func writeRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		http.Error(w, "resource has changed; reload and retry", http.StatusPreconditionFailed)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// validateRemindStatuses checks every status in the rule's remind_statuses
+// list and normalizes it to a comma-separated string without blanks
+func validateRemindStatuses(rr *models.ReminderRule) error {
+	if strings.TrimSpace(rr.RemindStatuses) == "" {
+		rr.RemindStatuses = ""
+		return nil
+	}
+	statuses := rr.Statuses()
+	for _, st := range statuses {
+		if !models.ValidStatus(st) {
+			return fmt.Errorf("invalid remind status: %s", st)
+		}
+	}
+	rr.RemindStatuses = strings.Join(statuses, ",")
+	return nil
+}
+
+// validateRemindTags normalizes the rule's remind_tags list to lowercase,
+// comma-separated tag names without blanks or duplicates
+func validateRemindTags(rr *models.ReminderRule) error {
+	var names []string
+	for _, t := range rr.TagNames() {
+		name, ok := models.NormalizeTagName(t)
+		if !ok {
+			return fmt.Errorf("invalid remind tag: %s", t)
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	rr.RemindTags = strings.Join(names, ",")
+	return nil
+}
+
 func (h *ReminderHandler) ListRules(w http.ResponseWriter, r *http.Request) {
 	rules, _ := h.Repo.ListRules()
 	json.NewEncoder(w).Encode(rules)
@@ -110,6 +186,10 @@ func (h *ReminderHandler) GetRule(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	if notModified(w, r, rr.Version) {
+		return
+	}
+	setETag(w, rr.Version)
 	json.NewEncoder(w).Encode(rr)
 }
 
@@ -127,77 +207,254 @@ func (h *ReminderHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// --- Validation for uniqueness ---
-	var rules []models.ReminderRule
-	if err := h.Repo.DB.Find(&rules).Error; err != nil {
-		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+	version, code, err := checkIfMatch(r, rr.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
 		return
 	}
+	rr.Version = version
 
-	for _, existing := range rules {
-		// skip self
-		if existing.ID == rr.ID {
-			continue
-		}
+	if code, err := h.validateRule(&in, rr.ID); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
 
-		switch in.RuleType {
-		case "at_due":
-			if existing.RuleType == "at_due" {
-				http.Error(w, "only one at_due rule is allowed", http.StatusBadRequest)
-				return
-			}
-		case "before_due":
-			var newParams service.BeforeDueParams
-			if err := json.Unmarshal([]byte(in.Params), &newParams); err != nil {
-				http.Error(w, "invalid params JSON", http.StatusBadRequest)
-				return
-			}
+	// --- Update values ---
+	rr.Name = in.Name
+	rr.Params = in.Params
+	rr.RuleType = in.RuleType
+	rr.RemindStatuses = in.RemindStatuses
+	rr.RemindTags = in.RemindTags
+	rr.Templates = in.Templates
 
-			if existing.RuleType == "before_due" {
-				var existingParams service.BeforeDueParams
-				if err := json.Unmarshal([]byte(existing.Params), &existingParams); err == nil {
-					if existingParams.MinutesBefore == newParams.MinutesBefore {
-						http.Error(w, fmt.Sprintf("before_due rule with %d minutes already exists", newParams.MinutesBefore), http.StatusBadRequest)
-						return
-					}
-				}
-			}
-		case "interval":
-			var newParams service.IntervalParams
-			if err := json.Unmarshal([]byte(in.Params), &newParams); err != nil {
-				http.Error(w, "invalid params JSON", http.StatusBadRequest)
-				return
-			}
+	if err := h.Repo.UpdateRule(rr); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	setETag(w, rr.Version)
 
-			if existing.RuleType == "interval" {
-				var existingParams service.IntervalParams
-				if err := json.Unmarshal([]byte(existing.Params), &existingParams); err == nil {
-					if existingParams.IntervalMin == newParams.IntervalMin {
-						http.Error(w, fmt.Sprintf("interval rule with %d minutes already exists", newParams.IntervalMin), http.StatusBadRequest)
-						return
-					}
-				}
-			}
-		}
+	h.svc.RuleChanged(rr.ID)
+	h.webhooks.Publish(service.EventRuleUpdated, rr)
+	_ = h.Repo.WriteAudit("rule.update", rr.Name)
+	json.NewEncoder(w).Encode(rr)
+}
+
+// PatchRule applies a JSON Merge Patch to a rule; only the fields sent are changed
+func (h *ReminderHandler) PatchRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	patch, code, err := readMergePatch(r, "id", "version", "last_run_at", "created_at", "updated_at")
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	rr, err := h.Repo.GetRuleByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	version, code, err := checkIfMatch(r, rr.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	rr.Version = version
+
+	in, err := service.ApplyMergePatch(rr, patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if code, err := h.validateRule(in, rr.ID); err != nil {
+		http.Error(w, err.Error(), code)
+		return
 	}
 
 	// --- Update values ---
 	rr.Name = in.Name
 	rr.Params = in.Params
 	rr.RuleType = in.RuleType
+	rr.RemindStatuses = in.RemindStatuses
+	rr.RemindTags = in.RemindTags
+	rr.Templates = in.Templates
+	rr.Active = in.Active
 
 	if err := h.Repo.UpdateRule(rr); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRuleError(w, err)
 		return
 	}
+	setETag(w, rr.Version)
 
-	_ = h.Repo.WriteAudit("rule.update", rr.Name)
+	h.svc.RuleChanged(rr.ID)
+	h.webhooks.Publish(service.EventRuleUpdated, rr)
+	_ = h.Repo.WriteAudit("rule.update", fmt.Sprintf("%s (fields: %s)", rr.Name, patchFields(patch)))
 	json.NewEncoder(w).Encode(rr)
 }
 
+type previewRequest struct {
+	Rule           models.ReminderRule `json:"rule"`
+	HorizonMinutes int                 `json:"horizon_minutes"` // default 1440 (one day)
+}
+
+type previewResponse struct {
+	From      time.Time               `json:"from"`
+	To        time.Time               `json:"to"`
+	Firings   []service.PreviewFiring `json:"firings"`
+	Truncated bool                    `json:"truncated"`
+}
+
+// Preview reports when an unsaved rule would fire, without writing anything
+func (h *ReminderHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var in previewRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.HorizonMinutes == 0 {
+		in.HorizonMinutes = 24 * 60
+	}
+	horizon := time.Duration(in.HorizonMinutes) * time.Minute
+	if horizon <= 0 || horizon > service.MaxPreviewHorizon {
+		http.Error(w, fmt.Sprintf("horizon_minutes must be between 1 and %d", int(service.MaxPreviewHorizon.Minutes())), http.StatusBadRequest)
+		return
+	}
+	if err := validateRemindStatuses(&in.Rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRemindTags(&in.Rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from := time.Now()
+	to := from.Add(horizon)
+	firings, truncated, err := h.svc.Preview(&in.Rule, from, to)
+	if errors.Is(err, service.ErrInvalidRule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(previewResponse{From: from, To: to, Firings: firings, Truncated: truncated})
+}
+
+type renderRequest struct {
+	Rule    models.ReminderRule `json:"rule"`
+	TaskID  uint                `json:"task_id"` // optional; a sample task due in 5 minutes is used otherwise
+	Channel string              `json:"channel"` // default "log"
+}
+
+type renderResponse struct {
+	Channel string       `json:"channel"`
+	Task    *models.Task `json:"task"`
+	Message string       `json:"message"`
+}
+
+// Render shows the message an unsaved rule would send, without writing anything
+func (h *ReminderHandler) Render(w http.ResponseWriter, r *http.Request) {
+	var in renderRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Channel == "" {
+		in.Channel = service.DefaultChannel
+	}
+
+	now := time.Now()
+	task := service.SampleTask(now)
+	if in.TaskID != 0 {
+		t, err := h.Repo.GetTaskByID(in.TaskID)
+		if err != nil {
+			http.Error(w, "task not found", http.StatusNotFound)
+			return
+		}
+		task = t
+	}
+	msg, err := service.RenderMessage(&in.Rule, task, in.Channel, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(renderResponse{Channel: in.Channel, Task: task, Message: msg})
+}
+
+type executionsResponse struct {
+	Total      int64                      `json:"total"`
+	Limit      int                        `json:"limit"`
+	Offset     int                        `json:"offset"`
+	Executions []repository.RuleExecution `json:"executions"`
+}
+
+// Executions pages through a rule's execution history. Query: limit (default 50, max 500), offset.
+func (h *ReminderHandler) Executions(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if _, err := h.Repo.GetRuleByID(uint(id)); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	offset = max(offset, 0)
+
+	execs, total, err := h.Repo.ListRuleExecutions(uint(id), limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if execs == nil {
+		execs = []repository.RuleExecution{}
+	}
+	json.NewEncoder(w).Encode(executionsResponse{Total: total, Limit: limit, Offset: offset, Executions: execs})
+}
+
+// Stats summarizes how often a rule fired. Query: days (default 30, max 365).
+func (h *ReminderHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	rr, err := h.Repo.GetRuleByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+
+	st, err := h.svc.RuleStats(rr, days, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
 func (h *ReminderHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	_ = h.Repo.DeleteRule(uint(id))
+	rr, err := h.Repo.GetRuleByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	version, code, err := checkIfMatch(r, rr.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if err := h.Repo.DeleteRule(uint(id), version); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	h.svc.RuleDeleted(uint(id))
+	h.webhooks.Publish(service.EventRuleDeleted, rr)
 	_ = h.Repo.WriteAudit("rule.delete", strconv.Itoa(id))
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -205,6 +462,8 @@ func (h *ReminderHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
 func (h *ReminderHandler) Activate(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 	_ = h.Repo.SetRuleActive(uint(id), true)
+	h.svc.RuleChanged(uint(id))
+	h.publishRuleUpdated(uint(id))
 	_ = h.Repo.WriteAudit("rule.activate", strconv.Itoa(id))
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -212,6 +471,14 @@ func (h *ReminderHandler) Activate(w http.ResponseWriter, r *http.Request) {
 func (h *ReminderHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 	_ = h.Repo.SetRuleActive(uint(id), false)
+	h.svc.RuleDeleted(uint(id))
+	h.publishRuleUpdated(uint(id))
 	_ = h.Repo.WriteAudit("rule.deactivate", strconv.Itoa(id))
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *ReminderHandler) publishRuleUpdated(id uint) {
+	if rr, err := h.Repo.GetRuleByID(id); err == nil {
+		h.webhooks.Publish(service.EventRuleUpdated, rr)
+	}
+}
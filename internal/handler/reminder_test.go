@@ -0,0 +1,30 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRuleTemplatesValidated(t *testing.T) {
+	s := newTestServer(t)
+	rule := func(templates string) string {
+		b, _ := json.Marshal(map[string]any{"name": "at due", "active": true, "rule_type": "at_due", "templates": templates})
+		return string(b)
+	}
+
+	res, body := s.do(t, "POST", "/rules", rule(`{"default": "{{.Task.Nope}}"}`))
+	expect(t, res, body, http.StatusBadRequest)
+	res, body = s.do(t, "POST", "/rules", rule(`{"default": "{{.Task.Title}} {{.DueIn}}"}`))
+	expect(t, res, body, http.StatusOK)
+
+	var rr struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(body), &rr); err != nil {
+		t.Fatal(err)
+	}
+	patch := `{"templates": "{\"log\": \"{{.Task.Title\"}"}`
+	res, body = s.do(t, "PATCH", "/rules/"+itoa(rr.ID), patch, "If-Match", `"1"`)
+	expect(t, res, body, http.StatusBadRequest)
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type SearchHandler struct {
+	svc  *service.SearchService
+	Repo *repository.GormRepo
+}
+
+func NewSearchHandler(svc *service.SearchService, repo *repository.GormRepo) *SearchHandler {
+	return &SearchHandler{svc: svc, Repo: repo}
+}
+
+// Register all Search endpoints
+func (h *SearchHandler) Register(r chi.Router) {
+	r.Get("/search", h.Search)
+}
+
+// Search finds tasks and comments. Query: q, limit (default 20, max 100), offset.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	offset = max(offset, 0)
+	page, err := h.svc.Search(q.Get("q"), limit, offset)
+	if errors.Is(err, service.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(page)
+}
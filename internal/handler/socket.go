@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/Nehyan9895/reminder-system/internal/ws"
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+// Socket heartbeat: the server pings every socketPing and drops clients it
+// has not heard from (any frame, including pongs) for socketIdle
+const (
+	socketPing = 30 * time.Second
+	socketIdle = 70 * time.Second
+)
+
+type SocketHandler struct {
+	svc  *service.SocketService
+	Repo *repository.GormRepo
+}
+
+func NewSocketHandler(svc *service.SocketService, repo *repository.GormRepo) *SocketHandler {
+	return &SocketHandler{svc: svc, Repo: repo}
+}
+
+// Register all Socket endpoints
+func (h *SocketHandler) Register(r chi.Router) {
+	r.Route("/ws", func(r chi.Router) {
+		r.Get("/", h.Connect)
+		r.Post("/sessions", h.CreateSession)
+		r.Get("/sessions", h.ListSessions)
+		r.Delete("/sessions/{id}", h.DeleteSession)
+	})
+}
+
+type createSessionRequest struct {
+	User       string `json:"user"`
+	TTLMinutes int    `json:"ttl_minutes"` // default 30 days
+}
+
+type createSessionResponse struct {
+	ID        uint      `json:"id"`
+	User      string    `json:"user"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token"`
+}
+
+// CreateSession issues a token for connecting to /ws; it is only shown once
+func (h *SocketHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	var in createSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.TTLMinutes < 0 {
+		http.Error(w, "ttl_minutes must not be negative", http.StatusBadRequest)
+		return
+	}
+	sess, token, err := h.svc.CreateSession(in.User, time.Duration(in.TTLMinutes)*time.Minute)
+	if errors.Is(err, service.ErrInvalidSession) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("ws.session.create", fmt.Sprintf("session #%d for %s", sess.ID, sess.User))
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createSessionResponse{ID: sess.ID, User: sess.User, ExpiresAt: sess.ExpiresAt, Token: token})
+}
+
+type sessionsResponse struct {
+	Sessions  []models.SocketSession `json:"sessions"`
+	Connected map[string]int         `json:"connected"` // open sockets per user
+}
+
+// ListSessions returns the unexpired sessions and who is connected right now
+func (h *SocketHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Repo.ListSocketSessions(time.Now())
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(sessionsResponse{Sessions: list, Connected: h.svc.Connected()})
+}
+
+// DeleteSession revokes a token and closes the sockets opened with it
+func (h *SocketHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	closed, err := h.svc.RevokeSession(uint(id))
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("ws.session.delete", fmt.Sprintf("session #%d, %d sockets closed", id, closed))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Connect upgrades to a WebSocket for the session's user. The token comes
+// from "Authorization: Bearer <token>" or, for browsers, ?token=.
+func (h *SocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	sess, err := h.svc.SessionByToken(token)
+	if errors.Is(err, service.ErrInvalidSession) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		log.Warnf("[ws] upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := h.svc.Connect(sess)
+	defer h.svc.Disconnect(client)
+	client.Reply(service.SocketMessage{Type: service.MsgHello, User: sess.User})
+
+	// the writer owns everything sent to the socket except pongs
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ping := time.NewTicker(socketPing)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.Context().Done():
+				_ = conn.WriteClose(ws.CloseGoingAway, "server shutting down")
+				conn.Close()
+				return
+			case <-client.Revoked():
+				_ = conn.WriteClose(ws.ClosePolicyViolation, "session revoked")
+				conn.Close()
+				return
+			case m := <-client.Send:
+				b, _ := json.Marshal(m)
+				if err := conn.WriteText(b); err != nil {
+					conn.Close()
+					return
+				}
+			case <-ping.C:
+				if err := conn.WritePing(); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	alive := func() { _ = conn.SetReadDeadline(time.Now().Add(socketIdle)) }
+	conn.PongHandler = alive
+	for {
+		alive()
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if op != ws.OpText {
+			_ = conn.WriteClose(ws.CloseUnsupportedData, "only text messages are supported")
+			return
+		}
+		client.Reply(h.svc.Handle(client, data))
+	}
+}
@@ -0,0 +1,106 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialSocket opens /ws with token and returns the connection past the handshake
+func (s *testServer) dialSocket(t *testing.T, token string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	nc, err := net.Dial("tcp", strings.TrimPrefix(s.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nc.Close() })
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	req := "GET /ws?token=" + token + " HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := nc.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(nc)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade: got %d", res.StatusCode)
+	}
+	return nc, br
+}
+
+// readFrame reads one short unmasked server frame
+func readFrame(t *testing.T, br *bufio.Reader) (opcode byte, payload []byte) {
+	t.Helper()
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	payload = make([]byte, hdr[1]&0x7F)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return hdr[0] & 0x0F, payload
+}
+
+func TestDeleteSessionClosesSockets(t *testing.T) {
+	s := newTestServer(t)
+	session := func(user string) (id uint, token string) {
+		t.Helper()
+		res, body := s.do(t, http.MethodPost, "/ws/sessions", `{"user":"`+user+`"}`)
+		expect(t, res, body, http.StatusCreated)
+		var out struct {
+			ID    uint   `json:"id"`
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal([]byte(body), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.ID, out.Token
+	}
+	revokedID, revokedToken := session("ann")
+	_, keptToken := session("ann")
+
+	_, revoked := s.dialSocket(t, revokedToken)
+	_, kept := s.dialSocket(t, keptToken)
+	for _, br := range []*bufio.Reader{revoked, kept} {
+		if op, payload := readFrame(t, br); op != 0x1 || !strings.Contains(string(payload), `"hello"`) {
+			t.Fatalf("got opcode %d %s, want hello", op, payload)
+		}
+	}
+
+	res, body := s.do(t, http.MethodDelete, "/ws/sessions/"+itoa(revokedID), "")
+	expect(t, res, body, http.StatusNoContent)
+
+	op, payload := readFrame(t, revoked)
+	if op != 0x8 || binary.BigEndian.Uint16(payload) != 1008 {
+		t.Fatalf("got opcode %d %q, want close 1008", op, payload)
+	}
+	if _, err := revoked.ReadByte(); err != io.EOF {
+		t.Errorf("revoked socket still open: %v", err)
+	}
+
+	// the other session of the same user stays connected; the revoked
+	// socket unregisters once its handler returns
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		res, body = s.do(t, http.MethodGet, "/ws/sessions", "")
+		expect(t, res, body, http.StatusOK)
+		if strings.Contains(body, `"connected":{"ann":1}`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions = %s, want ann connected once", body)
+		}
+	}
+	// and the revoked token no longer connects
+	res, body = s.do(t, http.MethodGet, "/ws?token="+revokedToken, "")
+	expect(t, res, body, http.StatusUnauthorized)
+}
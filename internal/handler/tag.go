@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type TagHandler struct {
+	svc  *service.TaskService
+	Repo *repository.GormRepo
+}
+
+func NewTagHandler(svc *service.TaskService, repo *repository.GormRepo) *TagHandler {
+	return &TagHandler{svc: svc, Repo: repo}
+}
+
+// Register all Tag endpoints
+func (h *TagHandler) Register(r chi.Router) {
+	r.Route("/tags", func(r chi.Router) {
+		r.Get("/", h.List)
+		r.Post("/", h.Create)
+		r.Put("/{id}", h.Rename)
+		r.Delete("/{id}", h.Delete)
+	})
+}
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTag):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrTagExists), errors.Is(err, repository.ErrTagInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// List returns every tag with the number of tasks carrying it
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.Repo.ListTags()
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []repository.TagCount{}
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *TagHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tag, err := h.svc.CreateTag(in.Name)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	_ = h.Repo.WriteAudit("tag.create", tag.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// Rename changes a tag's name on every task carrying it
+func (h *TagHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	var in tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tag, old, err := h.svc.RenameTag(uint(id), in.Name)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	_ = h.Repo.WriteAudit("tag.update", fmt.Sprintf("%s -> %s", old, tag.Name))
+	json.NewEncoder(w).Encode(tag)
+}
+
+// Delete removes a tag from every task and deletes it
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	tag, err := h.svc.DeleteTag(uint(id))
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+	_ = h.Repo.WriteAudit("tag.delete", tag.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
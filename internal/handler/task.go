@@ -2,23 +2,29 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Nehyan9895/reminder-system/internal/models"
 	"github.com/Nehyan9895/reminder-system/internal/repository"
 	"github.com/Nehyan9895/reminder-system/internal/service"
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 )
 
 type TaskHandler struct {
-	svc  *service.TaskService
-	Repo *repository.GormRepo
+	svc       *service.TaskService
+	reminders *service.ReminderService
+	Repo      *repository.GormRepo
 }
 
-func NewTaskHandler(svc *service.TaskService, repo *repository.GormRepo) *TaskHandler {
-	return &TaskHandler{svc: svc, Repo: repo}
+func NewTaskHandler(svc *service.TaskService, reminders *service.ReminderService, repo *repository.GormRepo) *TaskHandler {
+	return &TaskHandler{svc: svc, reminders: reminders, Repo: repo}
 }
 
 // Register Chi routes
@@ -28,31 +34,113 @@ func (h *TaskHandler) Register(r chi.Router) {
 		r.Get("/", h.List)
 		r.Get("/{id}", h.Get)
 		r.Put("/{id}", h.Update)
+		r.Patch("/{id}", h.Patch)
 		r.Delete("/{id}", h.Delete)
+		r.Post("/{id}/transition", h.Transition)
+		r.Get("/{id}/reminders", h.Reminders)
+		r.Get("/{id}/subtasks", h.Subtasks)
+		r.Post("/{id}/comments", h.AddComment)
+		r.Get("/{id}/comments", h.Comments)
+		r.Get("/{id}/activity", h.Activity)
+		r.Post("/{id}/dependencies", h.AddDependency)
+		r.Delete("/{id}/dependencies/{dep}", h.RemoveDependency)
 	})
+	r.Post("/tasks:batch", h.Batch)
+	r.Post("/tasks:import", h.Import)
+}
+
+// writeTaskError maps TaskService errors onto HTTP status codes
+func writeTaskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrInvalidTag), errors.Is(err, service.ErrInvalidRelation),
+		errors.Is(err, service.ErrInvalidComment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrOpenSubtasks), errors.Is(err, service.ErrParentDone):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrVersionConflict):
+		http.Error(w, "resource has changed; reload and retry", http.StatusPreconditionFailed)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// createTaskRequest is a task plus an optional due phrase ("tomorrow 9am")
+// used instead of due_at, resolved in timezone (IANA name, default UTC)
+type createTaskRequest struct {
+	models.Task
+	Due      string `json:"due"`
+	Timezone string `json:"timezone"`
+}
+
+// dueResolved tells the client how a due phrase was read
+type dueResolved struct {
+	Phrase   string    `json:"phrase"`
+	DueAt    time.Time `json:"due_at"`
+	Timezone string    `json:"timezone"`
+}
+
+type createTaskResponse struct {
+	*models.Task
+	DueResolved *dueResolved `json:"due_resolved,omitempty"`
 }
 
 func (h *TaskHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var task models.Task
-	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+	var req createTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	task := req.Task
+	var resolved *dueResolved
+	if req.Due != "" {
+		if !task.DueAt.IsZero() {
+			http.Error(w, "send either due or due_at, not both", http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if req.Timezone != "" {
+			l, err := time.LoadLocation(req.Timezone)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("unknown timezone %q", req.Timezone), http.StatusBadRequest)
+				return
+			}
+			loc = l
+		}
+		at, err := service.ParseDue(req.Due, time.Now(), loc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		task.DueAt = at
+		resolved = &dueResolved{Phrase: req.Due, DueAt: at, Timezone: loc.String()}
+	}
 	if err := h.svc.Create(&task); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTaskError(w, err)
 		return
 	}
+	setETag(w, task.Version)
 
 	// Write audit log
-	_ = h.Repo.WriteAudit("task.create", task.Title)
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.create", task.Title)
 
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(createTaskResponse{Task: &task, DueResolved: resolved})
 }
 
+// List returns all tasks; ?tag=a&tag=b (or ?tag=a,b) keeps those carrying every tag
 func (h *TaskHandler) List(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.svc.List()
+	var tags []string
+	for _, v := range r.URL.Query()["tag"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	tasks, err := h.svc.List(tags)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTaskError(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(tasks)
@@ -65,6 +153,10 @@ func (h *TaskHandler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	if notModified(w, r, task.Version) {
+		return
+	}
+	setETag(w, task.Version)
 	json.NewEncoder(w).Encode(task)
 }
 
@@ -75,32 +167,354 @@ func (h *TaskHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	current, err := h.svc.Get(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	version, code, err := checkIfMatch(r, current.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
 	task.ID = uint(id)
+	task.Version = version
 	if err := h.svc.Update(&task); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeTaskError(w, err)
 		return
 	}
+	setETag(w, task.Version)
 
 	// Write audit log
 	statusMsg := ""
 	if task.Status != "" {
 		statusMsg = fmt.Sprintf("status updated to %s", task.Status)
 	}
-	_ = h.Repo.WriteAudit("task.update", fmt.Sprintf("%s (%s)", task.Title, statusMsg))
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.update", fmt.Sprintf("%s (%s)", task.Title, statusMsg))
 
 	json.NewEncoder(w).Encode(task)
 }
 
-func (h *TaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
+// Patch applies a JSON Merge Patch to a task; only the fields sent are changed.
+// Status changes still go through the state machine.
+func (h *TaskHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	patch, code, err := readMergePatch(r, service.TaskReadOnlyFields...)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	current, err := h.svc.Get(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	task, err := service.ApplyMergePatch(current, patch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, code, err := checkIfMatch(r, current.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	task.ID = current.ID
+	task.Version = version
+	if err := h.svc.Update(task); err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	setETag(w, task.Version)
+
+	// Write audit log
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.update", fmt.Sprintf("%s (fields: %s)", task.Title, patchFields(patch)))
+
+	json.NewEncoder(w).Encode(task)
+}
+
+type transitionRequest struct {
+	Status string `json:"status"`
+}
+
+func (h *TaskHandler) Transition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	var in transitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// If-Match is optional here; when sent it must match the current version
+	var version uint
+	if im := r.Header.Get("If-Match"); im != "" && im != "*" {
+		current, err := h.svc.Get(uint(id))
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		v, code, err := checkIfMatch(r, current.Version, false)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		version = v
+	}
+	task, from, err := h.svc.Transition(uint(id), in.Status, version)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	setETag(w, task.Version)
+
+	// Write audit log
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.transition", fmt.Sprintf("%s (%s -> %s)", task.Title, from, task.Status))
+
+	json.NewEncoder(w).Encode(task)
+}
+
+// Subtasks lists the direct subtasks of a task
+func (h *TaskHandler) Subtasks(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	tasks, err := h.svc.Subtasks(uint(id))
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(tasks)
+}
+
+type dependencyRequest struct {
+	DependsOn uint `json:"depends_on"`
+}
+
+// AddDependency makes the task wait for another one; reminders for it are
+// held back while that task is open
+func (h *TaskHandler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	var in dependencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task, err := h.svc.AddDependency(uint(id), in.DependsOn)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.dependency.add", fmt.Sprintf("%s (#%d) waits for #%d", task.Title, task.ID, in.DependsOn))
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	dep, _ := strconv.Atoi(chi.URLParam(r, "dep"))
+	task, err := h.svc.RemoveDependency(uint(id), uint(dep))
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.dependency.remove", fmt.Sprintf("%s (#%d) no longer waits for #%d", task.Title, task.ID, dep))
+	json.NewEncoder(w).Encode(task)
+}
+
+type commentRequest struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+func (h *TaskHandler) AddComment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	var in commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := h.svc.AddComment(uint(id), in.Author, in.Body)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	author := c.Author
+	if author == "" {
+		author = "anonymous"
+	}
+	_ = h.Repo.WriteTaskAudit(c.TaskID, "task.comment", fmt.Sprintf("comment #%d on task #%d by %s", c.ID, c.TaskID, author))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Comments lists a task's comments, newest first. Query: limit (default 100, max 1000).
+func (h *TaskHandler) Comments(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	comments, err := h.svc.Comments(uint(id), limit)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(comments)
+}
+
+// Activity returns the task's history as one timeline, oldest first: comments,
+// status changes, reminders and other audit entries. Query: limit (the most
+// recent items kept; default 500, max 2000).
+func (h *TaskHandler) Activity(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 2000 {
+		limit = 500
+	}
+	items, err := h.svc.Activity(uint(id), limit)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+type batchRequest struct {
+	Operations []service.BatchOp `json:"operations"`
+}
+
+type batchResponse struct {
+	CorrelationID string                `json:"correlation_id"`
+	Results       []service.BatchResult `json:"results"`
+	Error         string                `json:"error,omitempty"`
+}
+
+// Batch applies many create/update/complete/delete operations in one
+// transaction. All audit entries share the batch's correlation ID, which is
+// taken from X-Correlation-ID when the client sends one.
+func (h *TaskHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var in batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = service.NewCorrelationID()
+	}
+	w.Header().Set("X-Correlation-ID", correlationID)
+
+	results, err := h.svc.Batch(in.Operations, correlationID)
+	switch {
+	case errors.Is(err, service.ErrBatchEmpty), errors.Is(err, service.ErrBatchTooLarge):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrBatchFailed):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(batchResponse{CorrelationID: correlationID, Results: results, Error: err.Error()})
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(batchResponse{CorrelationID: correlationID, Results: results})
+}
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 10 << 20
+
+// Import creates tasks from a CSV or JSON file sent as the request body.
+// Query: format=csv|json (defaults from Content-Type), dry_run=true, tz=<IANA zone>.
+func (h *TaskHandler) Import(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "text/csv" {
+			format = service.ImportCSV
+		} else {
+			format = service.ImportJSON
+		}
+	}
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("unknown timezone %q", tz), http.StatusBadRequest)
+			return
+		}
+		loc = l
+	}
+	dryRun, _ := strconv.ParseBool(q.Get("dry_run"))
+
+	recs, err := service.ParseImport(http.MaxBytesReader(w, r.Body, maxImportBytes), format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = service.NewCorrelationID()
+	}
+	report, err := h.svc.Import(recs, loc, dryRun, correlationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+type remindersResponse struct {
+	Task       *models.Task               `json:"task"`
+	Executions []repository.TaskExecution `json:"executions"`
+	Upcoming   []service.UpcomingReminder `json:"upcoming"`
+}
+
+// Reminders shows the reminders a task received and, per active rule, when
+// the next one is due or why none will be sent
+func (h *TaskHandler) Reminders(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	task, _ := h.svc.Get(uint(id)) // optional: get task title before deletion
-	if err := h.svc.Delete(uint(id)); err != nil {
+	task, err := h.svc.Get(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	execs, err := h.Repo.ListTaskExecutions(task.ID, 200)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upcoming, err := h.reminders.Upcoming(task, time.Now())
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if execs == nil {
+		execs = []repository.TaskExecution{}
+	}
+	json.NewEncoder(w).Encode(remindersResponse{Task: task, Executions: execs, Upcoming: upcoming})
+}
+
+func (h *TaskHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	task, err := h.svc.Get(uint(id)) // get task title and version before deletion
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	version, code, err := checkIfMatch(r, task.Version, true)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if err := h.svc.Delete(uint(id), version); err != nil {
+		writeTaskError(w, err)
+		return
+	}
 
 	// Write audit log
-	_ = h.Repo.WriteAudit("task.delete", task.Title)
+	_ = h.Repo.WriteTaskAudit(task.ID, "task.delete", task.Title)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookHandler struct {
+	svc  *service.WebhookService
+	Repo *repository.GormRepo
+}
+
+func NewWebhookHandler(svc *service.WebhookService, repo *repository.GormRepo) *WebhookHandler {
+	return &WebhookHandler{svc: svc, Repo: repo}
+}
+
+// Register all Webhook endpoints
+func (h *WebhookHandler) Register(r chi.Router) {
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Post("/", h.Create)
+		r.Get("/", h.List)
+		r.Get("/{id}", h.Get)
+		r.Put("/{id}", h.Update)
+		r.Delete("/{id}", h.Delete)
+		r.Post("/{id}/ping", h.Ping)
+		r.Get("/{id}/deliveries", h.Deliveries)
+	})
+}
+
+type webhookRequest struct {
+	URL    string `json:"url"`
+	Events string `json:"events"`
+	Active *bool  `json:"active"` // defaults to true on create
+}
+
+type createWebhookResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidWebhook) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+}
+
+// Create subscribes a URL to events; the signing secret is only shown once
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wh := models.Webhook{URL: in.URL, Events: in.Events, Active: in.Active == nil || *in.Active}
+	if err := h.svc.Create(&wh); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	_ = h.Repo.WriteAudit("webhook.create", fmt.Sprintf("webhook #%d -> %s", wh.ID, wh.URL))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createWebhookResponse{Webhook: wh, Secret: wh.Secret})
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.Repo.ListWebhooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(hooks)
+}
+
+func (h *WebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	wh, err := h.Repo.GetWebhookByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(wh)
+}
+
+// Update changes the URL, event filter or active flag; the secret is kept
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	wh, err := h.Repo.GetWebhookByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var in webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wh.URL, wh.Events = in.URL, in.Events
+	if in.Active != nil {
+		wh.Active = *in.Active
+	}
+	if err := h.svc.Validate(wh); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if err := h.Repo.UpdateWebhook(wh); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	_ = h.Repo.WriteAudit("webhook.update", fmt.Sprintf("webhook #%d -> %s", wh.ID, wh.URL))
+	json.NewEncoder(w).Encode(wh)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if err := h.Repo.DeleteWebhook(uint(id)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = h.Repo.WriteAudit("webhook.delete", strconv.Itoa(id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Ping queues a signed test event; its outcome shows up in the delivery log
+func (h *WebhookHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	wh, err := h.Repo.GetWebhookByID(uint(id))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	n, err := h.svc.Ping(wh)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"notification_id": n.ID, "event": n.Event})
+}
+
+type webhookDeliveriesResponse struct {
+	Total    int64                       `json:"total"`
+	Limit    int                         `json:"limit"`
+	Offset   int                         `json:"offset"`
+	Attempts []repository.WebhookAttempt `json:"attempts"`
+}
+
+// Deliveries pages through a webhook's delivery attempts. Query: limit (default 50, max 500), offset.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if _, err := h.Repo.GetWebhookByID(uint(id)); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	offset = max(offset, 0)
+
+	attempts, total, err := h.Repo.ListWebhookAttempts(uint(id), limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if attempts == nil {
+		attempts = []repository.WebhookAttempt{}
+	}
+	json.NewEncoder(w).Encode(webhookDeliveriesResponse{Total: total, Limit: limit, Offset: offset, Attempts: attempts})
+}
@@ -0,0 +1,180 @@
+// Package ical writes and reads the small subset of iCalendar (RFC 5545)
+// used for task feeds: VTODO/VEVENT components with VALARM reminders.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const utcLayout = "20060102T150405Z"
+
+// Alarm is a display reminder relative to the item's due time
+type Alarm struct {
+	Before      time.Duration
+	Description string
+}
+
+// Item is one task rendered as a VTODO or VEVENT
+type Item struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+	Duration    time.Duration // VEVENT length; defaults to 15 minutes
+	Status      string        // RFC 5545 STATUS value
+	Completed   *time.Time
+	Stamp       time.Time
+	Alarms      []Alarm
+}
+
+// Writer emits a VCALENDAR with folded, CRLF-terminated content lines
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// line writes one content line, folding it at 75 octets
+func (cw *Writer) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	l := name + ":" + value
+	// continuation lines start with a space, which counts towards the limit
+	for limit := 75; len(l) > limit; limit = 74 {
+		cut := limit
+		// don't split a UTF-8 sequence
+		for cut > 0 && l[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		_, cw.err = cw.w.WriteString(l[:cut] + "\r\n ")
+		l = l[cut:]
+	}
+	_, cw.err = cw.w.WriteString(l + "\r\n")
+}
+
+// Begin starts the calendar
+func (cw *Writer) Begin(name string) {
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", "-//reminder-system//tasks//EN")
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("X-WR-CALNAME", Escape(name))
+}
+
+// Todo writes the item as a VTODO with alarms relative to DUE
+func (cw *Writer) Todo(it Item) {
+	cw.line("BEGIN", "VTODO")
+	cw.common(it)
+	cw.line("DUE", it.Due.UTC().Format(utcLayout))
+	if it.Completed != nil {
+		cw.line("COMPLETED", it.Completed.UTC().Format(utcLayout))
+	}
+	cw.alarms(it, "END")
+	cw.line("END", "VTODO")
+}
+
+// Event writes the item as a VEVENT starting at the due time
+func (cw *Writer) Event(it Item) {
+	d := it.Duration
+	if d <= 0 {
+		d = 15 * time.Minute
+	}
+	cw.line("BEGIN", "VEVENT")
+	cw.common(it)
+	cw.line("DTSTART", it.Due.UTC().Format(utcLayout))
+	cw.line("DTEND", it.Due.Add(d).UTC().Format(utcLayout))
+	cw.alarms(it, "START")
+	cw.line("END", "VEVENT")
+}
+
+func (cw *Writer) common(it Item) {
+	cw.line("UID", it.UID)
+	cw.line("DTSTAMP", it.Stamp.UTC().Format(utcLayout))
+	cw.line("SUMMARY", Escape(it.Summary))
+	if it.Description != "" {
+		cw.line("DESCRIPTION", Escape(it.Description))
+	}
+	if it.Status != "" {
+		cw.line("STATUS", it.Status)
+	}
+}
+
+func (cw *Writer) alarms(it Item, related string) {
+	for _, a := range it.Alarms {
+		cw.line("BEGIN", "VALARM")
+		cw.line("ACTION", "DISPLAY")
+		cw.line("DESCRIPTION", Escape(a.Description))
+		cw.line("TRIGGER;RELATED="+related, "-"+Duration(a.Before))
+		cw.line("END", "VALARM")
+	}
+}
+
+// End closes the calendar and flushes
+func (cw *Writer) End() error {
+	cw.line("END", "VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// Escape escapes a TEXT value
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// Unescape reverses Escape
+func Unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Duration formats a non-negative duration as an RFC 5545 DURATION (e.g. PT15M)
+func Duration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
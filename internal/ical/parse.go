@@ -0,0 +1,168 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Parsed is a VEVENT or VTODO read from a calendar file
+type Parsed struct {
+	Component   string // "VEVENT" or "VTODO"
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time // DTSTART for events, DUE (or DTSTART) for todos
+	Err         error     // set when the component could not be understood
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads every VEVENT and VTODO from r. Components with bad dates are
+// returned with Err set so callers can report them per item.
+func Parse(r io.Reader) ([]Parsed, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out    []Parsed
+		cur    *Parsed
+		props  map[string]property
+		depth  int // nesting inside the current component (VALARM etc.)
+		sawCal bool
+	)
+	for _, l := range lines {
+		p, ok := parseLine(l)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && p.value == "VCALENDAR":
+			sawCal = true
+		case p.name == "BEGIN" && cur == nil && (p.value == "VEVENT" || p.value == "VTODO"):
+			cur = &Parsed{Component: p.value}
+			props = map[string]property{}
+		case p.name == "BEGIN" && cur != nil:
+			depth++
+		case p.name == "END" && cur != nil && depth > 0:
+			depth--
+		case p.name == "END" && cur != nil && p.value == cur.Component:
+			finish(cur, props)
+			out = append(out, *cur)
+			cur = nil
+		case cur != nil && depth == 0:
+			if _, dup := props[p.name]; !dup {
+				props[p.name] = p
+			}
+		}
+	}
+	if !sawCal {
+		return nil, fmt.Errorf("not an iCalendar file (missing BEGIN:VCALENDAR)")
+	}
+	return out, nil
+}
+
+func finish(c *Parsed, props map[string]property) {
+	c.UID = props["UID"].value
+	c.Summary = Unescape(props["SUMMARY"].value)
+	c.Description = Unescape(props["DESCRIPTION"].value)
+
+	key := "DTSTART"
+	if c.Component == "VTODO" {
+		if _, ok := props["DUE"]; ok {
+			key = "DUE"
+		}
+	}
+	p, ok := props[key]
+	if !ok {
+		c.Err = fmt.Errorf("%s has no %s", c.Component, key)
+		return
+	}
+	t, err := parseTime(p)
+	if err != nil {
+		c.Err = fmt.Errorf("%s: %w", key, err)
+		return
+	}
+	c.Due = t
+}
+
+// parseTime handles UTC, floating, TZID-qualified and DATE values
+func parseTime(p property) (time.Time, error) {
+	v := p.value
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		return time.ParseInLocation("20060102", v, locationFor(p))
+	}
+	if strings.HasSuffix(v, "Z") {
+		return time.Parse(utcLayout, v)
+	}
+	return time.ParseInLocation("20060102T150405", v, locationFor(p))
+}
+
+func locationFor(p property) *time.Location {
+	if tz := p.params["TZID"]; tz != "" {
+		if loc, err := time.LoadLocation(strings.Trim(tz, `"`)); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// unfold joins continuation lines (RFC 5545 3.1)
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if len(l) > 0 && (l[0] == ' ' || l[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, sc.Err()
+}
+
+func parseLine(l string) (property, bool) {
+	colon := valueColon(l)
+	if colon < 0 {
+		return property{}, false
+	}
+	head, value := l[:colon], l[colon+1:]
+	parts := strings.Split(head, ";")
+	p := property{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: value}
+	for _, kv := range parts[1:] {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			p.params[strings.ToUpper(k)] = v
+		}
+	}
+	if p.name == "BEGIN" || p.name == "END" {
+		p.value = strings.ToUpper(strings.TrimSpace(p.value))
+	}
+	return p, true
+}
+
+// valueColon finds the colon separating name/params from the value, skipping
+// colons inside quoted parameter values
+func valueColon(l string) int {
+	quoted := false
+	for i := 0; i < len(l); i++ {
+		switch l[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
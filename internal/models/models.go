@@ -1,43 +1,328 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Task statuses
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+// taskTransitions lists the statuses each status may move to
+var taskTransitions = map[string][]string{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusTodo},
+	StatusCancelled:  {StatusTodo},
+}
+
+// OpenStatuses are the statuses that receive reminders unless a rule says otherwise
+var OpenStatuses = []string{StatusTodo, StatusInProgress, StatusBlocked}
+
+// Statuses lists every task status
+var Statuses = []string{StatusTodo, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled}
+
+// ValidStatus reports whether s is a known task status
+func ValidStatus(s string) bool {
+	_, ok := taskTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a task may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, s := range taskTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
 
 // Task: seed at least 5 of these
 type Task struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	DueAt       time.Time `json:"due_at"`
-	Status      string    `json:"status"` // "pending", "done"
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	DueAt        time.Time  `json:"due_at"`
+	Status       string     `gorm:"index" json:"status"`   // see Status* constants
+	Assignee     string     `gorm:"index" json:"assignee"` // who is reminded; empty = unassigned
+	CompletedAt  *time.Time `json:"completed_at"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	SnoozedUntil *time.Time `json:"snoozed_until"` // reminders due before then are sent then
+	Tags         []Tag      `gorm:"many2many:task_tags" json:"tags"`
+	ParentID     *uint      `gorm:"index" json:"parent_id"`            // subtask of; a parent cannot be done while subtasks are open
+	Version      uint       `gorm:"not null;default:1" json:"version"` // bumped on every write, exposed as ETag
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// tasks that must be finished first; see Blocked
+	BlockedBy []Task `gorm:"many2many:task_dependencies;joinForeignKey:TaskID;joinReferences:DependsOnID" json:"blocked_by,omitempty"`
+}
+
+// IsOpen reports whether the task still has to be worked on
+func (t *Task) IsOpen() bool {
+	return slices.Contains(OpenStatuses, t.Status)
+}
+
+// Blocked reports whether one of the task's dependencies is still open;
+// t.BlockedBy must be loaded
+func (t *Task) Blocked() bool {
+	return slices.ContainsFunc(t.BlockedBy, func(dep Task) bool { return dep.IsOpen() })
 }
 
 // ReminderRule: generic parameters encoded as JSON string (simple)
 type ReminderRule struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	Name      string     `json:"name"`
-	Active    bool       `json:"active"`
-	RuleType  string     `json:"rule_type"`               // "before_due", "interval","at_due"
-	Params    string     `gorm:"type:TEXT" json:"params"` // JSON string
-	LastRunAt *time.Time `json:"last_run_at"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Name           string     `json:"name"`
+	Active         bool       `json:"active"`
+	RuleType       string     `json:"rule_type"`                  // "before_due", "interval","at_due"
+	Params         string     `gorm:"type:TEXT" json:"params"`    // JSON string
+	RemindStatuses string     `json:"remind_statuses"`            // comma-separated task statuses; empty = OpenStatuses
+	RemindTags     string     `json:"remind_tags"`                // comma-separated tag names; only tasks with one of them are reminded; empty = all
+	Templates      string     `gorm:"type:TEXT" json:"templates"` // JSON object of channel (or "default") -> message template; empty = built-in
+	LastRunAt      *time.Time `json:"last_run_at"`
+	Version        uint       `gorm:"not null;default:1" json:"version"` // bumped on every edit, exposed as ETag
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Statuses returns the task statuses this rule reminds on
+func (rr *ReminderRule) Statuses() []string {
+	if strings.TrimSpace(rr.RemindStatuses) == "" {
+		return OpenStatuses
+	}
+	var out []string
+	for _, s := range strings.Split(rr.RemindStatuses, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// TagNames returns the tags this rule is limited to; nil means any task
+func (rr *ReminderRule) TagNames() []string {
+	var out []string
+	for _, t := range strings.Split(rr.RemindTags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// AppliesTo reports whether the rule reminds on t given its status and tags;
+// t.Tags must be loaded
+func (rr *ReminderRule) AppliesTo(t *Task) bool {
+	if !slices.Contains(rr.Statuses(), t.Status) {
+		return false
+	}
+	names := rr.TagNames()
+	return len(names) == 0 || slices.ContainsFunc(t.Tags, func(tag Tag) bool { return slices.Contains(names, tag.Name) })
+}
+
+// MaxTagLength caps tag names (in characters)
+const MaxTagLength = 50
+
+// Tag labels tasks. Names are lowercase and unique.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UnmarshalJSON also accepts a bare name, so tasks can be sent with
+// "tags": ["billing"]
+func (t *Tag) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = Tag{Name: name}
+		return nil
+	}
+	type plain Tag
+	return json.Unmarshal(b, (*plain)(t))
+}
+
+// NormalizeTagName trims and lowercases a tag name and reports whether it is
+// valid: 1 to MaxTagLength characters without commas
+func NormalizeTagName(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	n := utf8.RuneCountInString(name)
+	return name, n > 0 && n <= MaxTagLength && !strings.Contains(name, ",")
 }
 
 // AuditLog stores actions and scheduler-triggered events
 type AuditLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	EventType     string    `json:"event_type"` // rule.create, rule.update, reminder.trigger
+	Details       string    `gorm:"type:TEXT" json:"details"`
+	CorrelationID string    `gorm:"index" json:"correlation_id,omitempty"` // groups entries written by one request, e.g. a batch
+	TaskID        uint      `gorm:"index" json:"task_id,omitempty"`        // the task the entry is about, if any
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MaxCommentLength caps comment bodies (in characters)
+const MaxCommentLength = 10000
+
+// Comment is a note left on a task
+type Comment struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	EventType string    `json:"event_type"` // rule.create, rule.update, reminder.trigger
-	Details   string    `gorm:"type:TEXT" json:"details"`
+	TaskID    uint      `gorm:"index" json:"task_id"`
+	Author    string    `json:"author"` // free text, e.g. an assignee name; empty = anonymous
+	Body      string    `gorm:"type:TEXT;not null" json:"body"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// TaskStatusChange records one move of a task through the state machine
+type TaskStatusChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TaskID    uint      `gorm:"index" json:"task_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor,omitempty"` // who made the change and how, e.g. "ann via reminder link"; empty for API calls
+	ChangedAt time.Time `json:"changed_at"`
+}
+
 // ReminderExecution prevents duplicate triggers (one row per triggered rule+task)
 type ReminderExecution struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
-	RuleID      uint      `json:"rule_id"`
-	TaskID      uint      `json:"task_id"`
-	TriggeredAt time.Time `json:"triggered_at"`
+	RuleID      uint      `gorm:"index:idx_exec_rule_task_time,priority:1" json:"rule_id"`
+	TaskID      uint      `gorm:"index:idx_exec_rule_task_time,priority:2;index" json:"task_id"`
+	TriggeredAt time.Time `gorm:"index:idx_exec_rule_task_time,priority:3" json:"triggered_at"`
 	CreatedAt   time.Time `json:"created_at"`
 }
+
+// Notification statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationDead    = "dead"     // retries exhausted; see DeadLetter
+	NotificationHeld    = "held"     // waiting to be merged into a digest
+	NotificationDigest  = "digested" // merged into the digest notification DigestID
+	NotificationDropped = "dropped"  // can never be delivered (e.g. its webhook was deleted)
+)
+
+// Notification is a transactional outbox row: it is written in the same
+// transaction as the execution and audit entry, then delivered by the dispatcher
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	RuleID        uint       `gorm:"index" json:"rule_id"`
+	TaskID        uint       `gorm:"index" json:"task_id"`
+	Channel       string     `json:"channel"`
+	Recipient     string     `gorm:"index" json:"recipient"` // task assignee; empty = unassigned
+	DigestID      uint       `gorm:"index" json:"digest_id,omitempty"`
+	WebhookID     uint       `gorm:"index" json:"webhook_id,omitempty"` // webhook channel only
+	Event         string     `json:"event,omitempty"`                   // webhook event type, or "digest" for digests
+	Message       string     `gorm:"type:TEXT" json:"message"`
+	Status        string     `gorm:"index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `gorm:"type:TEXT" json:"last_error"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"` // nil = as soon as possible; for held rows, when the digest is sent
+	SentAt        *time.Time `json:"sent_at"`
+	AckedAt       *time.Time `json:"acked_at"` // acknowledged by the recipient (ws channel)
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DigestRecipientAll is the DigestSetting recipient that applies to everyone
+// without a setting of their own
+const DigestRecipientAll = "*"
+
+// DigestSetting merges the reminders one recipient gets on a channel within
+// a window into a single summary
+type DigestSetting struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Recipient     string    `gorm:"uniqueIndex:idx_digest_recipient_channel" json:"recipient"` // task assignee, "" for unassigned or "*"
+	Channel       string    `gorm:"uniqueIndex:idx_digest_recipient_channel" json:"channel"`
+	WindowMinutes int       `json:"window_minutes"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Webhook is an outbound subscription to system events. Deliveries are
+// signed with Secret (HMAC-SHA256).
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	URL       string    `gorm:"not null" json:"url"`
+	Secret    string    `gorm:"not null" json:"-"` // only shown when the webhook is created
+	Events    string    `json:"events"`            // comma-separated event types; empty = all
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventTypes returns the subscribed event types; nil means all
+func (wh *Webhook) EventTypes() []string {
+	var out []string
+	for _, e := range strings.Split(wh.Events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Subscribes reports whether the webhook wants events of the given type
+func (wh *Webhook) Subscribes(event string) bool {
+	types := wh.EventTypes()
+	return len(types) == 0 || slices.Contains(types, event)
+}
+
+// DeliveryAttempt records one try at delivering a notification
+type DeliveryAttempt struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	NotificationID uint      `gorm:"index" json:"notification_id"`
+	Attempt        int       `json:"attempt"`
+	Error          string    `gorm:"type:TEXT" json:"error"` // empty on success
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// DeadLetter holds a notification whose delivery failed permanently
+type DeadLetter struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	NotificationID uint      `gorm:"uniqueIndex" json:"notification_id"`
+	RuleID         uint      `json:"rule_id"`
+	TaskID         uint      `json:"task_id"`
+	Channel        string    `json:"channel"`
+	Message        string    `gorm:"type:TEXT" json:"message"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `gorm:"type:TEXT" json:"last_error"`
+	FailedAt       time.Time `json:"failed_at"`
+}
+
+// CalendarFeed is a secret iCalendar feed URL handed to one person; only a
+// hash of the token is stored
+type CalendarFeed struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Owner     string    `json:"owner"`
+	TokenHash string    `gorm:"uniqueIndex" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SocketSession lets User connect to /ws until ExpiresAt; only a hash of the
+// token is stored
+type SocketSession struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	User      string    `gorm:"index" json:"user"` // matches Task.Assignee
+	TokenHash string    `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Tables lists every model the server migrates
+func Tables() []any {
+	return []any{&Tag{}, &Task{}, &ReminderRule{}, &AuditLog{}, &ReminderExecution{}, &CalendarFeed{},
+		&Notification{}, &DeliveryAttempt{}, &DeadLetter{}, &Webhook{},
+		&DigestSetting{}, &SocketSession{}, &Comment{}, &TaskStatusChange{}}
+}
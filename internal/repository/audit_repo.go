@@ -3,8 +3,60 @@ package repository
 import "github.com/Nehyan9895/reminder-system/internal/models"
 
 func (r *GormRepo) WriteAudit(eventType, details string) error {
-	return r.DB.Create(&models.AuditLog{
-		EventType: eventType,
-		Details:   details,
-	}).Error
+	return r.WriteAuditCorrelated(eventType, details, "")
+}
+
+// WriteAuditCorrelated writes an audit entry tagged with a correlation ID
+func (r *GormRepo) WriteAuditCorrelated(eventType, details, correlationID string) error {
+	return r.writeAudit(&models.AuditLog{EventType: eventType, Details: details, CorrelationID: correlationID})
+}
+
+// WriteTaskAudit writes an audit entry about a task, so it shows up in the
+// task's activity feed
+func (r *GormRepo) WriteTaskAudit(taskID uint, eventType, details string) error {
+	return r.WriteTaskAuditCorrelated(taskID, eventType, details, "")
+}
+
+// WriteTaskAuditCorrelated writes an audit entry about a task tagged with a correlation ID
+func (r *GormRepo) WriteTaskAuditCorrelated(taskID uint, eventType, details, correlationID string) error {
+	return r.writeAudit(&models.AuditLog{EventType: eventType, Details: details, CorrelationID: correlationID, TaskID: taskID})
+}
+
+func (r *GormRepo) writeAudit(entry *models.AuditLog) error {
+	err := r.DB.Create(entry).Error
+	if err == nil {
+		r.notifyAudit()
+	}
+	return err
+}
+
+// ListTaskAudit returns up to limit audit entries about a task, newest
+// first, leaving out the given event types
+func (r *GormRepo) ListTaskAudit(taskID uint, exclude []string, limit int) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	q := r.DB.Where("task_id = ?", taskID)
+	if len(exclude) > 0 {
+		q = q.Where("event_type NOT IN ?", exclude)
+	}
+	err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// AuditSince returns up to limit audit entries with an ID above afterID, oldest first
+func (r *GormRepo) AuditSince(afterID uint, limit int) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	err := r.DB.Where("id > ?", afterID).Order("id").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// LastAuditID returns the highest audit entry ID, or 0 if there are none
+func (r *GormRepo) LastAuditID() (uint, error) {
+	var id *uint
+	if err := r.DB.Model(&models.AuditLog{}).Select("MAX(id)").Scan(&id).Error; err != nil {
+		return 0, err
+	}
+	if id == nil {
+		return 0, nil
+	}
+	return *id, nil
 }
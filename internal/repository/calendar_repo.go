@@ -0,0 +1,27 @@
+package repository
+
+import "github.com/Nehyan9895/reminder-system/internal/models"
+
+func (r *GormRepo) CreateCalendarFeed(f *models.CalendarFeed) error {
+	return r.DB.Create(f).Error
+}
+
+func (r *GormRepo) ListCalendarFeeds() ([]models.CalendarFeed, error) {
+	var list []models.CalendarFeed
+	if err := r.DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *GormRepo) GetCalendarFeedByTokenHash(hash string) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	if err := r.DB.Where("token_hash = ?", hash).First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *GormRepo) DeleteCalendarFeed(id uint) error {
+	return r.DB.Delete(&models.CalendarFeed{}, id).Error
+}
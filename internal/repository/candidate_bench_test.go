@@ -0,0 +1,113 @@
+package repository_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+// seedCandidates creates a rule and n open tasks, each with execs executions
+// of the rule, and returns the rule
+func seedCandidates(tb testing.TB, repo *repository.GormRepo, n, execs int) *models.ReminderRule {
+	tb.Helper()
+	rr := &models.ReminderRule{Name: "every 5 min", Active: true, RuleType: "interval", Params: `{"interval_min":5}`}
+	if err := repo.CreateRule(rr); err != nil {
+		tb.Fatal(err)
+	}
+	base := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	tasks := make([]models.Task, n)
+	for i := range tasks {
+		tasks[i] = models.Task{Title: fmt.Sprintf("task %d", i), DueAt: base.Add(time.Duration(i) * time.Minute), Status: models.StatusTodo, Version: 1}
+	}
+	if err := repo.DB.CreateInBatches(tasks, 500).Error; err != nil {
+		tb.Fatal(err)
+	}
+	var rows []models.ReminderExecution
+	for i := range tasks {
+		for j := 0; j < execs; j++ {
+			rows = append(rows, models.ReminderExecution{RuleID: rr.ID, TaskID: tasks[i].ID, TriggeredAt: tasks[i].DueAt.Add(time.Duration(j*5) * time.Minute)})
+		}
+	}
+	if len(rows) > 0 {
+		if err := repo.DB.CreateInBatches(rows, 500).Error; err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return rr
+}
+
+// perTaskCandidates is how candidates were loaded before CandidateTasks: the
+// tasks, then one LastExecutionTime query per task
+func perTaskCandidates(repo *repository.GormRepo, rr *models.ReminderRule) (map[uint]*time.Time, error) {
+	var tasks []models.Task
+	if err := repo.DB.Where("status IN ?", rr.Statuses()).Order("due_at").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]*time.Time, len(tasks))
+	for _, t := range tasks {
+		last, err := repo.LastExecutionTime(rr.ID, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		out[t.ID] = last
+	}
+	return out, nil
+}
+
+func TestCandidateTasksLastTriggered(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	rr := seedCandidates(t, repo, 20, 3)
+	// a task that never fired
+	if err := repo.CreateTask(&models.Task{Title: "new", DueAt: time.Now(), Status: models.StatusTodo}); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := perTaskCandidates(repo, rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.CandidateTasks(rr.ID, rr.Statuses(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("CandidateTasks returned %d tasks, want %d", len(got), len(want))
+	}
+	for _, c := range got {
+		w := want[c.ID]
+		switch {
+		case w == nil && c.LastTriggeredAt != nil:
+			t.Errorf("task %d: last triggered %v, want never", c.ID, *c.LastTriggeredAt)
+		case w != nil && (c.LastTriggeredAt == nil || !c.LastTriggeredAt.Equal(*w)):
+			t.Errorf("task %d: last triggered %v, want %v", c.ID, c.LastTriggeredAt, *w)
+		}
+	}
+}
+
+// BenchmarkCandidateTasks compares one LastExecutionTime query per task with
+// the single joined CandidateTasks query
+func BenchmarkCandidateTasks(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		repo := repository.NewGormRepo(repotest.Open(b))
+		rr := seedCandidates(b, repo, n, 5)
+
+		b.Run(fmt.Sprintf("per-task/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := perTaskCandidates(repo, rr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("joined/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.CandidateTasks(rr.ID, rr.Statuses(), nil, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package repository
+
+import "github.com/Nehyan9895/reminder-system/internal/models"
+
+func (r *GormRepo) CreateComment(c *models.Comment) error {
+	return r.DB.Create(c).Error
+}
+
+// ListComments returns up to limit comments on a task, newest first
+func (r *GormRepo) ListComments(taskID uint, limit int) ([]models.Comment, error) {
+	var list []models.Comment
+	err := r.DB.Where("task_id = ?", taskID).Order("created_at DESC, id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+func (r *GormRepo) CreateStatusChange(c *models.TaskStatusChange) error {
+	return r.DB.Create(c).Error
+}
+
+// ListStatusChanges returns up to limit status changes of a task, newest first
+func (r *GormRepo) ListStatusChanges(taskID uint, limit int) ([]models.TaskStatusChange, error) {
+	var list []models.TaskStatusChange
+	err := r.DB.Where("task_id = ?", taskID).Order("changed_at DESC, id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// ListSubtasks returns the direct subtasks of a task
+func (r *GormRepo) ListSubtasks(parentID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := r.withRelations().Where("parent_id = ?", parentID).Order("due_at").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// CountOpenSubtasks counts the direct subtasks of a task that are still open
+func (r *GormRepo) CountOpenSubtasks(parentID uint) (int64, error) {
+	var n int64
+	err := r.DB.Model(&models.Task{}).
+		Where("parent_id = ? AND status IN ?", parentID, models.OpenStatuses).
+		Count(&n).Error
+	return n, err
+}
+
+// dependencyLockKey identifies the advisory lock taken by LockDependencies
+const dependencyLockKey = 0x74646570 // "tdep"
+
+// LockDependencies serializes dependency changes until the transaction
+// ends, so that a cycle check and the insert it allows cannot interleave with
+// another one. Two new dependencies can close a cycle without sharing a task,
+// so row locks would not do. On postgres this is an advisory lock; sqlite
+// allows a single writer, and a transaction that read before another one
+// committed fails when it writes.
+func (r *GormRepo) LockDependencies() error {
+	if r.DB.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return r.DB.Exec("SELECT pg_advisory_xact_lock(?)", dependencyLockKey).Error
+}
+
+// subtaskLockKey identifies the advisory lock taken by LockSubtasks
+const subtaskLockKey = 0x74737562 // "tsub"
+
+// LockSubtasks serializes completing tasks against adding or reopening
+// subtasks until the transaction ends, so a parent cannot be marked done
+// while a concurrent write gives it an open subtask. Like LockDependencies
+// it is an advisory lock on postgres and a no-op on sqlite.
+func (r *GormRepo) LockSubtasks() error {
+	if r.DB.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return r.DB.Exec("SELECT pg_advisory_xact_lock(?)", subtaskLockKey).Error
+}
+
+// TaskStatus returns the status of a task
+func (r *GormRepo) TaskStatus(id uint) (string, error) {
+	var t models.Task
+	if err := r.DB.Select("id", "status").First(&t, id).Error; err != nil {
+		return "", err
+	}
+	return t.Status, nil
+}
+
+// ParentIDOf returns the parent of a task, or nil for a top-level task
+func (r *GormRepo) ParentIDOf(id uint) (*uint, error) {
+	var t models.Task
+	if err := r.DB.Select("id", "parent_id").First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return t.ParentID, nil
+}
+
+// DependencyIDs returns the tasks any of ids directly depend on
+func (r *GormRepo) DependencyIDs(ids []uint) ([]uint, error) {
+	var deps []uint
+	err := r.DB.Table("task_dependencies").Where("task_id IN ?", ids).Distinct().Pluck("depends_on_id", &deps).Error
+	return deps, err
+}
+
+// DependentTaskIDs returns the tasks that directly depend on id
+func (r *GormRepo) DependentTaskIDs(id uint) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Table("task_dependencies").Where("depends_on_id = ?", id).Pluck("task_id", &ids).Error
+	return ids, err
+}
+
+// AddDependency records that taskID cannot start before dependsOnID is
+// finished; adding an existing dependency is a no-op
+func (r *GormRepo) AddDependency(taskID, dependsOnID uint) error {
+	row := map[string]any{"task_id": taskID, "depends_on_id": dependsOnID}
+	return r.DB.Table("task_dependencies").Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error
+}
+
+// RemoveDependency deletes a dependency and reports whether it existed
+func (r *GormRepo) RemoveDependency(taskID, dependsOnID uint) (bool, error) {
+	res := r.DB.Exec("DELETE FROM task_dependencies WHERE task_id = ? AND depends_on_id = ?", taskID, dependsOnID)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// UpsertDigestSetting creates or replaces the setting for its recipient and channel
+func (r *GormRepo) UpsertDigestSetting(ds *models.DigestSetting) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "recipient"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"window_minutes", "updated_at"}),
+	}).Create(ds).Error
+}
+
+func (r *GormRepo) ListDigestSettings() ([]models.DigestSetting, error) {
+	var list []models.DigestSetting
+	if err := r.DB.Order("channel, recipient").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *GormRepo) DeleteDigestSetting(id uint) error {
+	return r.DB.Delete(&models.DigestSetting{}, id).Error
+}
+
+// DigestSettingFor returns the recipient's setting for channel, falling back
+// to the "*" setting; nil if neither exists
+func (r *GormRepo) DigestSettingFor(recipient, channel string) (*models.DigestSetting, error) {
+	var list []models.DigestSetting
+	if err := r.DB.Where("channel = ? AND recipient IN ?", channel, []string{recipient, models.DigestRecipientAll}).
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	var found *models.DigestSetting
+	for i := range list {
+		if list[i].Recipient == recipient || found == nil {
+			found = &list[i]
+		}
+	}
+	return found, nil
+}
+
+// HoldNotification stores n until its digest is sent. It joins the open
+// digest of its recipient and channel if there is one, otherwise it opens a
+// new digest due at flushAt.
+func (r *GormRepo) HoldNotification(n *models.Notification, flushAt time.Time) error {
+	var open struct{ At aggregateTime }
+	if err := r.DB.Model(&models.Notification{}).
+		Select("MIN(next_attempt_at) AS at").
+		Where("status = ? AND recipient = ? AND channel = ?", models.NotificationHeld, n.Recipient, n.Channel).
+		Scan(&open).Error; err != nil {
+		return err
+	}
+	if open.At.Time != nil {
+		flushAt = *open.At.Time
+	}
+	n.Status = models.NotificationHeld
+	n.NextAttemptAt = &flushAt
+	return r.DB.Create(n).Error
+}
+
+// DigestGroup identifies the held notifications merged into one digest
+type DigestGroup struct {
+	Recipient string
+	Channel   string
+}
+
+// DueDigestGroups returns the groups whose digest window has closed
+func (r *GormRepo) DueDigestGroups(now time.Time) ([]DigestGroup, error) {
+	var out []DigestGroup
+	err := r.DB.Model(&models.Notification{}).
+		Select("recipient, channel").
+		Where("status = ?", models.NotificationHeld).
+		Group("recipient, channel").
+		Having("MIN(next_attempt_at) <= ?", now).
+		Scan(&out).Error
+	return out, err
+}
+
+// FlushDigest locks the held notifications of g, queues the summary returned
+// by build as a pending notification and marks the held rows as merged into
+// it, all in one transaction
+func (r *GormRepo) FlushDigest(g DigestGroup, build func([]models.Notification) (*models.Notification, error)) error {
+	return r.Transaction(func(tx *GormRepo) error {
+		var held []models.Notification
+		if err := tx.DB.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND recipient = ? AND channel = ?", models.NotificationHeld, g.Recipient, g.Channel).
+			Order("id").
+			Find(&held).Error; err != nil {
+			return err
+		}
+		if len(held) == 0 {
+			return nil
+		}
+		digest, err := build(held)
+		if err != nil {
+			return err
+		}
+		digest.Recipient, digest.Channel = g.Recipient, g.Channel
+		if err := tx.CreateNotification(digest); err != nil {
+			return err
+		}
+		ids := make([]uint, len(held))
+		for i := range held {
+			ids[i] = held[i].ID
+		}
+		return tx.DB.Model(&models.Notification{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{"status": models.NotificationDigest, "digest_id": digest.ID, "next_attempt_at": nil}).Error
+	})
+}
@@ -14,12 +14,127 @@ func (r *GormRepo) CreateExecution(ruleID, taskID uint, triggeredAt time.Time) e
 	}).Error
 }
 
-func (r *GormRepo) HasExecutionSince(ruleID, taskID uint, since time.Time) (bool, error) {
-	var cnt int64
+// LastExecutionsForTask returns the latest execution time of every rule that
+// fired for the task, keyed by rule ID, in one query
+func (r *GormRepo) LastExecutionsForTask(taskID uint) (map[uint]time.Time, error) {
+	var rows []struct {
+		RuleID uint
+		Last   aggregateTime
+	}
+	if err := r.DB.Model(&models.ReminderExecution{}).
+		Select("rule_id, MAX(triggered_at) AS last").
+		Where("task_id = ?", taskID).
+		Group("rule_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]time.Time, len(rows))
+	for _, row := range rows {
+		if row.Last.Time != nil {
+			out[row.RuleID] = *row.Last.Time
+		}
+	}
+	return out, nil
+}
+
+// TaskExecution is a reminder execution joined with its rule
+type TaskExecution struct {
+	ID          uint      `json:"id"`
+	RuleID      uint      `json:"rule_id"`
+	RuleName    string    `json:"rule_name"` // empty if the rule was deleted
+	RuleType    string    `json:"rule_type"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// ListTaskExecutions returns the executions for a task, newest first
+func (r *GormRepo) ListTaskExecutions(taskID uint, limit int) ([]TaskExecution, error) {
+	var out []TaskExecution
+	if err := r.DB.Table("reminder_executions AS e").
+		Select("e.id, e.rule_id, COALESCE(r.name, '') AS rule_name, COALESCE(r.rule_type, '') AS rule_type, e.triggered_at").
+		Joins("LEFT JOIN reminder_rules AS r ON r.id = e.rule_id").
+		Where("e.task_id = ?", taskID).
+		Order("e.triggered_at DESC").
+		Limit(limit).
+		Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RuleExecution is a reminder execution joined with its task
+type RuleExecution struct {
+	ID          uint      `json:"id"`
+	TaskID      uint      `json:"task_id"`
+	TaskTitle   string    `json:"task_title"` // empty if the task was deleted
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// ListRuleExecutions pages through a rule's executions, newest first, and
+// returns the total count
+func (r *GormRepo) ListRuleExecutions(ruleID uint, limit, offset int) ([]RuleExecution, int64, error) {
+	var total int64
+	if err := r.DB.Model(&models.ReminderExecution{}).Where("rule_id = ?", ruleID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []RuleExecution
+	if err := r.DB.Table("reminder_executions AS e").
+		Select("e.id, e.task_id, COALESCE(t.title, '') AS task_title, e.triggered_at").
+		Joins("LEFT JOIN tasks AS t ON t.id = e.task_id").
+		Where("e.rule_id = ?", ruleID).
+		Order("e.triggered_at DESC, e.id DESC").
+		Limit(limit).Offset(offset).
+		Scan(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// DayCount is the number of executions on one calendar day
+type DayCount struct {
+	Day   time.Time `json:"day"`
+	Count int64     `json:"count"`
+}
+
+// ExecutionSummary aggregates a rule's executions
+type ExecutionSummary struct {
+	Total         int64
+	DistinctTasks int64
+	LastFiredAt   *time.Time
+}
+
+// SummarizeRuleExecutions counts all executions and distinct tasks of a rule
+func (r *GormRepo) SummarizeRuleExecutions(ruleID uint) (ExecutionSummary, error) {
+	var row struct {
+		Total         int64
+		DistinctTasks int64
+		LastFiredAt   aggregateTime
+	}
+	err := r.DB.Model(&models.ReminderExecution{}).
+		Select("COUNT(*) AS total, COUNT(DISTINCT task_id) AS distinct_tasks, MAX(triggered_at) AS last_fired_at").
+		Where("rule_id = ?", ruleID).
+		Scan(&row).Error
+	return ExecutionSummary{Total: row.Total, DistinctTasks: row.DistinctTasks, LastFiredAt: row.LastFiredAt.Time}, err
+}
+
+// RuleExecutionsPerDay counts a rule's executions per day since the given time
+func (r *GormRepo) RuleExecutionsPerDay(ruleID uint, since time.Time) ([]DayCount, error) {
+	var rows []struct {
+		Day   aggregateTime
+		Count int64
+	}
 	if err := r.DB.Model(&models.ReminderExecution{}).
-		Where("rule_id = ? AND task_id = ? AND triggered_at >= ?", ruleID, taskID, since).
-		Count(&cnt).Error; err != nil {
-		return false, err
+		Select("DATE(triggered_at) AS day, COUNT(*) AS count").
+		Where("rule_id = ? AND triggered_at >= ?", ruleID, since).
+		Group("DATE(triggered_at)").
+		Order("day").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]DayCount, 0, len(rows))
+	for _, row := range rows {
+		if row.Day.Time != nil {
+			out = append(out, DayCount{Day: *row.Day.Time, Count: row.Count})
+		}
 	}
-	return cnt > 0, nil
+	return out, nil
 }
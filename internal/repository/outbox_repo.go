@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateNotification queues a pending notification in the outbox
+func (r *GormRepo) CreateNotification(n *models.Notification) error {
+	n.Status = models.NotificationPending
+	return r.DB.Create(n).Error
+}
+
+// DeliveryOutcome is how the dispatcher resolved one delivery attempt
+type DeliveryOutcome struct {
+	Err      error
+	RetryAt  time.Time // when the notification will be retried, or when its digest is sent if Hold is set
+	Dead     bool      // when Err is set and no retries are left
+	Drop     bool      // when Err is set and the notification can never be delivered; it is not retried
+	Deferred bool      // not attempted (e.g. rate limited); does not count as an attempt
+	Hold     bool      // when Deferred: hold the notification for a digest
+}
+
+// minRetryDelay is the earliest a failed delivery is retried, so a retry is
+// never due again within the pass that failed it
+const minRetryDelay = time.Second
+
+// claimLease is how long a claimed notification is hidden from other
+// dispatchers while it is being delivered. If the dispatcher dies before it
+// records the outcome, the notification is retried once the lease expires.
+const claimLease = 5 * time.Minute
+
+// DeliverNext claims the oldest notification that is due for an attempt
+// (skipping rows other dispatchers hold), passes it to deliver and records
+// the attempt and its outcome. It reports false when nothing was due.
+//
+// Claiming and recording are two short transactions; deliver runs outside
+// of both, so a slow webhook does not hold a row lock or a connection.
+func (r *GormRepo) DeliverNext(now time.Time, deliver func(*models.Notification) DeliveryOutcome) (bool, error) {
+	n, err := r.claimNotification(now)
+	if n == nil || err != nil {
+		return false, err
+	}
+	start := time.Now()
+	out := deliver(n)
+	return true, r.recordDelivery(n, out, now, start)
+}
+
+// claimNotification locks the oldest due notification, counts the attempt
+// and leases it for claimLease. It returns nil when nothing is due.
+func (r *GormRepo) claimNotification(now time.Time) (*models.Notification, error) {
+	var n models.Notification
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", models.NotificationPending, now).
+			Order("id").
+			First(&n).Error
+		if err != nil {
+			return err
+		}
+		n.Attempts++
+		lease := now.Add(claimLease)
+		return tx.Model(&n).Updates(map[string]any{"attempts": n.Attempts, "next_attempt_at": lease}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// saveOutcome writes the delivery columns of n; the rest of the row (e.g. an
+// ack that arrived during delivery) is left alone
+func saveOutcome(tx *gorm.DB, n *models.Notification) error {
+	return tx.Model(n).Select("status", "attempts", "last_error", "next_attempt_at", "sent_at").Updates(n).Error
+}
+
+// recordDelivery stores the outcome of delivering a claimed notification
+func (r *GormRepo) recordDelivery(n *models.Notification, out DeliveryOutcome, now, start time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if out.Deferred {
+			n.Attempts--
+			n.NextAttemptAt = &out.RetryAt
+			if out.Hold {
+				n.Status = models.NotificationHeld
+			}
+			return saveOutcome(tx, n)
+		}
+		attempt := models.DeliveryAttempt{
+			NotificationID: n.ID,
+			Attempt:        n.Attempts,
+			DurationMs:     time.Since(start).Milliseconds(),
+			AttemptedAt:    start,
+		}
+
+		switch {
+		case out.Err == nil:
+			n.Status = models.NotificationSent
+			n.SentAt = &start
+			n.NextAttemptAt = nil
+			n.LastError = ""
+		case out.Drop:
+			attempt.Error = out.Err.Error()
+			n.Status = models.NotificationDropped
+			n.NextAttemptAt = nil
+			n.LastError = attempt.Error
+		case out.Dead:
+			attempt.Error = out.Err.Error()
+			n.Status = models.NotificationDead
+			n.NextAttemptAt = nil
+			n.LastError = attempt.Error
+			if err := tx.Create(&models.DeadLetter{
+				NotificationID: n.ID,
+				RuleID:         n.RuleID,
+				TaskID:         n.TaskID,
+				Channel:        n.Channel,
+				Message:        n.Message,
+				Attempts:       n.Attempts,
+				LastError:      n.LastError,
+				FailedAt:       start,
+			}).Error; err != nil {
+				return err
+			}
+		default:
+			attempt.Error = out.Err.Error()
+			retryAt := out.RetryAt
+			if !retryAt.After(now) {
+				retryAt = now.Add(minRetryDelay)
+			}
+			n.NextAttemptAt = &retryAt
+			n.LastError = attempt.Error
+		}
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return saveOutcome(tx, n)
+	})
+}
+
+// ListDeadLetters returns permanently failed deliveries, newest first
+func (r *GormRepo) ListDeadLetters(limit, offset int) ([]models.DeadLetter, int64, error) {
+	var total int64
+	if err := r.DB.Model(&models.DeadLetter{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []models.DeadLetter
+	err := r.DB.Order("failed_at DESC, id DESC").Limit(limit).Offset(offset).Find(&out).Error
+	return out, total, err
+}
+
+// RequeueDeadLetter moves a dead notification back to pending with a fresh
+// attempt budget. It returns gorm.ErrRecordNotFound if the notification is
+// not in the dead-letter table.
+func (r *GormRepo) RequeueDeadLetter(notificationID uint) (*models.DeadLetter, error) {
+	var dl models.DeadLetter
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("notification_id = ?", notificationID).First(&dl).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&dl).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Notification{}).
+			Where("id = ?", notificationID).
+			Updates(map[string]any{
+				"status":          models.NotificationPending,
+				"attempts":        0,
+				"next_attempt_at": nil,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// CountRuleDeliveryFailures counts failed delivery attempts of a rule's reminder
+// notifications (webhook deliveries are not counted)
+func (r *GormRepo) CountRuleDeliveryFailures(ruleID uint) (int64, error) {
+	var cnt int64
+	err := r.DB.Model(&models.DeliveryAttempt{}).
+		Joins("JOIN notifications ON notifications.id = delivery_attempts.notification_id").
+		Where("notifications.rule_id = ? AND notifications.webhook_id = 0 AND delivery_attempts.error <> ''", ruleID).
+		Count(&cnt).Error
+	return cnt, err
+}
@@ -0,0 +1,125 @@
+package repository_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+func TestDeliverNextDeliversOutsideTransaction(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	n := models.Notification{Channel: "test", Message: "hello"}
+	if err := repo.CreateNotification(&n); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	found, err := repo.DeliverNext(now, func(got *models.Notification) repository.DeliveryOutcome {
+		// the claim is committed before delivery starts
+		var claimed models.Notification
+		if err := repo.DB.First(&claimed, got.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if claimed.Attempts != 1 || claimed.NextAttemptAt == nil || !claimed.NextAttemptAt.After(now) {
+			t.Errorf("claimed row: attempts %d, next_attempt_at %v; want 1 and a lease", claimed.Attempts, claimed.NextAttemptAt)
+		}
+		// a second claim finds nothing while the lease holds
+		again, err := repo.DeliverNext(now, func(*models.Notification) repository.DeliveryOutcome {
+			t.Error("claimed notification delivered twice")
+			return repository.DeliveryOutcome{}
+		})
+		if err != nil || again {
+			t.Errorf("second DeliverNext = %v, %v; want nothing due", again, err)
+		}
+		// no transaction is open, so other writes go through
+		if err := repo.WriteAudit("test.write", "during delivery"); err != nil {
+			t.Errorf("write during delivery: %v", err)
+		}
+		return repository.DeliveryOutcome{}
+	})
+	if err != nil || !found {
+		t.Fatalf("DeliverNext = %v, %v", found, err)
+	}
+
+	var sent models.Notification
+	if err := repo.DB.First(&sent, n.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sent.Status != models.NotificationSent || sent.Attempts != 1 || sent.SentAt == nil || sent.NextAttemptAt != nil {
+		t.Errorf("got status %q attempts %d sent_at %v next_attempt_at %v", sent.Status, sent.Attempts, sent.SentAt, sent.NextAttemptAt)
+	}
+}
+
+func TestDeliverNextOutcomes(t *testing.T) {
+	now := time.Now()
+	fail := errors.New("boom")
+	tests := []struct {
+		name       string
+		out        repository.DeliveryOutcome
+		status     string
+		attempts   int
+		deadLetter bool
+	}{
+		{"retry", repository.DeliveryOutcome{Err: fail, RetryAt: now.Add(time.Minute)}, models.NotificationPending, 1, false},
+		{"dead", repository.DeliveryOutcome{Err: fail, Dead: true}, models.NotificationDead, 1, true},
+		{"drop", repository.DeliveryOutcome{Err: fail, Drop: true}, models.NotificationDropped, 1, false},
+		{"deferred", repository.DeliveryOutcome{Deferred: true, RetryAt: now.Add(time.Minute)}, models.NotificationPending, 0, false},
+		{"held", repository.DeliveryOutcome{Deferred: true, Hold: true, RetryAt: now.Add(time.Minute)}, models.NotificationHeld, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewGormRepo(repotest.Open(t))
+			n := models.Notification{Channel: "test", Message: "hello"}
+			if err := repo.CreateNotification(&n); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.DeliverNext(now, func(*models.Notification) repository.DeliveryOutcome { return tt.out }); err != nil {
+				t.Fatal(err)
+			}
+			var got models.Notification
+			if err := repo.DB.First(&got, n.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.status || got.Attempts != tt.attempts {
+				t.Errorf("status %q attempts %d, want %q and %d", got.Status, got.Attempts, tt.status, tt.attempts)
+			}
+			if tt.out.RetryAt.IsZero() != (got.NextAttemptAt == nil) {
+				t.Errorf("next_attempt_at = %v, want %v", got.NextAttemptAt, tt.out.RetryAt)
+			}
+			var letters int64
+			repo.DB.Model(&models.DeadLetter{}).Count(&letters)
+			if (letters == 1) != tt.deadLetter {
+				t.Errorf("%d dead letters", letters)
+			}
+		})
+	}
+}
+
+func TestAckDuringDeliveryIsKept(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	n := models.Notification{Channel: "ws", Recipient: "ann", Message: "hello"}
+	if err := repo.CreateNotification(&n); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repo.DeliverNext(time.Now(), func(got *models.Notification) repository.DeliveryOutcome {
+		// the client acks before the outcome is recorded
+		if ok, err := repo.AckNotification(got.ID, "ann", time.Now()); !ok || err != nil {
+			t.Errorf("ack = %v, %v", ok, err)
+		}
+		return repository.DeliveryOutcome{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got models.Notification
+	if err := repo.DB.First(&got, n.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.NotificationSent || got.AckedAt == nil {
+		t.Errorf("status %q acked_at %v, want sent and acked", got.Status, got.AckedAt)
+	}
+}
@@ -1,11 +1,106 @@
 package repository
 
-import "gorm.io/gorm"
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when a conditional write finds the row at a
+// different version than expected (or gone)
+var ErrVersionConflict = errors.New("version conflict")
 
 type GormRepo struct {
 	DB *gorm.DB
+
+	onAudit      func() // called once audit entries are committed
+	inTx         bool
+	pendingAudit bool // an audit entry was written in this transaction
 }
 
 func NewGormRepo(db *gorm.DB) *GormRepo {
 	return &GormRepo{DB: db}
 }
+
+// Transaction runs fn with a repo bound to a single database transaction;
+// the transaction is rolled back if fn returns an error
+func (r *GormRepo) Transaction(fn func(tx *GormRepo) error) error {
+	txRepo := &GormRepo{inTx: true}
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo.DB = tx
+		return fn(txRepo)
+	})
+	if err == nil && txRepo.pendingAudit {
+		r.notifyAudit()
+	}
+	return err
+}
+
+// OnAudit registers fn to be called after audit entries are committed, e.g.
+// to push them to live subscribers
+func (r *GormRepo) OnAudit(fn func()) {
+	r.onAudit = fn
+}
+
+// notifyAudit reports a written audit entry: right away, or on commit when r
+// is bound to a transaction
+func (r *GormRepo) notifyAudit() {
+	if r.inTx {
+		r.pendingAudit = true
+		return
+	}
+	if r.onAudit != nil {
+		r.onAudit()
+	}
+}
+
+// aggregateTime scans a timestamp computed in SQL, such as MAX(triggered_at).
+// PostgreSQL returns a timestamp; sqlite drops the column type and returns
+// the stored text.
+type aggregateTime struct {
+	Time *time.Time
+}
+
+// aggregateTimeLayouts are the text forms sqlite drivers store times in
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02", // DATE(...)
+}
+
+// Value lets gorm treat aggregateTime as a column; it is only ever scanned
+func (a aggregateTime) Value() (driver.Value, error) {
+	if a.Time == nil {
+		return nil, nil
+	}
+	return *a.Time, nil
+}
+
+func (a *aggregateTime) Scan(v any) error {
+	a.Time = nil
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		a.Time = &v
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a time", v)
+	}
+	for _, layout := range aggregateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			a.Time = &t
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse time %q", s)
+}
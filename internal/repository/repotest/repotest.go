@@ -0,0 +1,35 @@
+// Package repotest opens throwaway sqlite databases for tests
+package repotest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+)
+
+// Open returns a migrated sqlite database in a temporary directory that is
+// removed when the test ends
+func Open(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	dsn := filepath.Join(tb.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: "sqlite", DSN: dsn}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		tb.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.Tables()...); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -9,15 +9,48 @@ import (
 )
 
 func (r *GormRepo) CreateRule(rr *models.ReminderRule) error {
+	rr.Version = 1
 	return r.DB.Create(rr).Error
 }
 
+// UpdateRule saves rr only if the stored row is still at rr.Version and bumps
+// the version; otherwise ErrVersionConflict is returned and rr is unchanged.
+// LastRunAt is owned by the scheduler and never written here.
 func (r *GormRepo) UpdateRule(rr *models.ReminderRule) error {
-	return r.DB.Save(rr).Error
+	expected := rr.Version
+	rr.Version = expected + 1
+	rr.UpdatedAt = time.Now()
+	res := r.DB.Model(&models.ReminderRule{}).
+		Where("id = ? AND version = ?", rr.ID, expected).
+		Select("*").Omit("id", "created_at", "last_run_at").
+		Updates(rr)
+	if res.Error != nil || res.RowsAffected == 0 {
+		rr.Version = expected
+		if res.Error != nil {
+			return res.Error
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// SetRuleLastRun records a scheduler pass without touching the rule's version
+func (r *GormRepo) SetRuleLastRun(id uint, at time.Time) error {
+	return r.DB.Model(&models.ReminderRule{}).
+		Where("id = ?", id).
+		UpdateColumn("last_run_at", at).Error
 }
 
-func (r *GormRepo) DeleteRule(id uint) error {
-	return r.DB.Delete(&models.ReminderRule{}, id).Error
+// DeleteRule deletes the rule only if it is still at the given version
+func (r *GormRepo) DeleteRule(id, version uint) error {
+	res := r.DB.Where("version = ?", version).Delete(&models.ReminderRule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 func (r *GormRepo) GetRuleByID(id uint) (*models.ReminderRule, error) {
@@ -47,7 +80,7 @@ func (r *GormRepo) ActiveRules() ([]models.ReminderRule, error) {
 func (r *GormRepo) SetRuleActive(id uint, active bool) error {
 	return r.DB.Model(&models.ReminderRule{}).
 		Where("id = ?", id).
-		Update("active", active).Error
+		Updates(map[string]any{"active": active, "version": gorm.Expr("version + 1")}).Error
 }
 func (r *GormRepo) LastExecutionTime(ruleID, taskID uint) (*time.Time, error) {
 	var exec models.ReminderExecution
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Search highlight markers; ts_headline (or the LIKE fallback) wraps matches
+// in them and the service turns them into HTML
+const (
+	HighlightStart = "\x01"
+	HighlightStop  = "\x02"
+)
+
+// searchConfig is the text search configuration used for documents, queries
+// and the GIN indexes; they must agree for the indexes to be used
+const searchConfig = "english"
+
+// task and comment documents, exactly as indexed; titles weigh more than
+// descriptions
+const (
+	taskDocument = "(setweight(to_tsvector('" + searchConfig + "', coalesce(title, '')), 'A') || " +
+		"setweight(to_tsvector('" + searchConfig + "', coalesce(description, '')), 'B'))"
+	commentDocument = "to_tsvector('" + searchConfig + "', coalesce(body, ''))"
+)
+
+// maxLikeHits caps the matches the LIKE fallback ranks in memory
+const maxLikeHits = 1000
+
+// SearchHit is a task or comment matching a search. As read from the store,
+// Title and Snippet carry HighlightStart/HighlightStop markers around matched
+// words; SearchService replaces them with HTML.
+type SearchHit struct {
+	Kind      string    `json:"kind"` // "task" or "comment"
+	TaskID    uint      `json:"task_id"`
+	CommentID uint      `json:"comment_id,omitempty"`
+	Status    string    `json:"status"`
+	DueAt     time.Time `json:"due_at"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+}
+
+// FullTextSearch reports whether the store supports PostgreSQL text search
+func (r *GormRepo) FullTextSearch() bool {
+	return r.DB.Dialector.Name() == "postgres"
+}
+
+// EnsureSearchIndexes creates the GIN indexes full-text search uses; it does
+// nothing on stores without text search
+func (r *GormRepo) EnsureSearchIndexes() error {
+	if !r.FullTextSearch() {
+		return nil
+	}
+	if err := r.DB.Exec("CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (" + taskDocument + ")").Error; err != nil {
+		return err
+	}
+	return r.DB.Exec("CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN ((" + commentDocument + "))").Error
+}
+
+// SearchTasks ranks tasks (title and description) and comments against a
+// web-search style query and returns one page of hits with the total count
+func (r *GormRepo) SearchTasks(query string, limit, offset int) ([]SearchHit, int64, error) {
+	const headline = `'StartSel=` + HighlightStart + `, StopSel=` + HighlightStop + `, MaxFragments=2, MaxWords=30, MinWords=10'`
+	const titleHeadline = `'StartSel=` + HighlightStart + `, StopSel=` + HighlightStop + `, HighlightAll=true'`
+	hits := `
+		SELECT 'task' AS kind, tasks.id AS task_id, 0 AS comment_id, tasks.status, tasks.due_at,
+			ts_headline('` + searchConfig + `', tasks.title, q.query, ` + titleHeadline + `) AS title,
+			ts_headline('` + searchConfig + `', coalesce(tasks.description, ''), q.query, ` + headline + `) AS snippet,
+			ts_rank(` + taskDocument + `, q.query) AS rank
+		FROM tasks, q
+		WHERE ` + taskDocument + ` @@ q.query
+		UNION ALL
+		SELECT 'comment', tasks.id, comments.id, tasks.status, tasks.due_at,
+			tasks.title,
+			ts_headline('` + searchConfig + `', comments.body, q.query, ` + headline + `),
+			ts_rank(` + commentDocument + `, q.query)
+		FROM comments JOIN tasks ON tasks.id = comments.task_id, q
+		WHERE ` + commentDocument + ` @@ q.query`
+	with := "WITH q AS (SELECT websearch_to_tsquery('" + searchConfig + "', ?) AS query) "
+
+	count := "SELECT (SELECT COUNT(*) FROM tasks, q WHERE " + taskDocument + " @@ q.query) + " +
+		"(SELECT COUNT(*) FROM comments, q WHERE " + commentDocument + " @@ q.query)"
+
+	var total int64
+	if err := r.DB.Raw(with+count, query).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []SearchHit
+	if err := r.DB.Raw(with+"SELECT * FROM ("+hits+") AS hits ORDER BY rank DESC, task_id, comment_id LIMIT ? OFFSET ?",
+		query, limit, offset).Scan(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// likePattern matches term anywhere, with LIKE wildcards in it escaped
+func likePattern(term string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(strings.ToLower(term)) + "%"
+}
+
+// SearchTasksLike is the fallback for stores without text search: it returns
+// up to maxLikeHits tasks and as many comments containing every term,
+// unranked and without highlights, and how many match in all
+func (r *GormRepo) SearchTasksLike(terms []string) ([]SearchHit, int64, error) {
+	taskQ := r.DB.Table("tasks")
+	commentQ := r.DB.Table("comments").Joins("JOIN tasks ON tasks.id = comments.task_id")
+	for _, t := range terms {
+		p := likePattern(t)
+		taskQ = taskQ.Where(`LOWER(tasks.title || ' ' || COALESCE(tasks.description, '')) LIKE ? ESCAPE '\'`, p)
+		commentQ = commentQ.Where(`LOWER(comments.body) LIKE ? ESCAPE '\'`, p)
+	}
+	taskQ = taskQ.Session(&gorm.Session{})
+	commentQ = commentQ.Session(&gorm.Session{})
+
+	var nTasks, nComments int64
+	if err := taskQ.Count(&nTasks).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := commentQ.Count(&nComments).Error; err != nil {
+		return nil, 0, err
+	}
+	var tasks, comments []SearchHit
+	if err := taskQ.
+		Select("'task' AS kind, tasks.id AS task_id, 0 AS comment_id, tasks.status, tasks.due_at, tasks.title, tasks.description AS snippet").
+		Order("tasks.id").Limit(maxLikeHits).Scan(&tasks).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := commentQ.
+		Select("'comment' AS kind, tasks.id AS task_id, comments.id AS comment_id, tasks.status, tasks.due_at, tasks.title, comments.body AS snippet").
+		Order("comments.id").Limit(maxLikeHits).Scan(&comments).Error; err != nil {
+		return nil, 0, err
+	}
+	return append(tasks, comments...), nTasks + nComments, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+)
+
+func (r *GormRepo) CreateSocketSession(s *models.SocketSession) error {
+	return r.DB.Create(s).Error
+}
+
+// ListSocketSessions returns the sessions that have not expired
+func (r *GormRepo) ListSocketSessions(now time.Time) ([]models.SocketSession, error) {
+	var list []models.SocketSession
+	if err := r.DB.Where("expires_at > ?", now).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *GormRepo) GetSocketSessionByTokenHash(hash string) (*models.SocketSession, error) {
+	var s models.SocketSession
+	if err := r.DB.Where("token_hash = ?", hash).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *GormRepo) DeleteSocketSession(id uint) error {
+	return r.DB.Delete(&models.SocketSession{}, id).Error
+}
+
+// RetryRecipientNow makes the recipient's undelivered notifications on
+// channel due immediately, e.g. when they come online after failed attempts.
+// Notifications sent after unackedSince that were never acknowledged count as
+// undelivered: the socket may have dropped them, so they are sent again.
+func (r *GormRepo) RetryRecipientNow(recipient, channel string, unackedSince time.Time) (int64, error) {
+	res := r.DB.Model(&models.Notification{}).
+		Where("recipient = ? AND channel = ?", recipient, channel).
+		Where(r.DB.Where("status = ? AND next_attempt_at IS NOT NULL", models.NotificationPending).
+			Or("status = ? AND acked_at IS NULL AND sent_at > ?", models.NotificationSent, unackedSince)).
+		Updates(map[string]any{"status": models.NotificationPending, "next_attempt_at": nil, "sent_at": nil})
+	return res.RowsAffected, res.Error
+}
+
+// AckNotification records that the recipient saw a notification addressed
+// to them; it reports false for unknown or already acknowledged ones. Pending
+// rows match too: the client can ack before the dispatcher has recorded the
+// delivery, which leaves acked_at alone.
+func (r *GormRepo) AckNotification(id uint, recipient string, at time.Time) (bool, error) {
+	res := r.DB.Model(&models.Notification{}).
+		Where("id = ? AND recipient = ? AND status IN ? AND acked_at IS NULL", id, recipient,
+			[]string{models.NotificationPending, models.NotificationSent}).
+		Update("acked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagCount is a tag with the number of tasks carrying it
+type TagCount struct {
+	models.Tag `gorm:"embedded"`
+	Tasks      int64 `json:"tasks"`
+}
+
+func (r *GormRepo) ListTags() ([]TagCount, error) {
+	var list []TagCount
+	err := r.DB.Model(&models.Tag{}).
+		Select("tags.*, COUNT(task_tags.task_id) AS tasks").
+		Joins("LEFT JOIN task_tags ON task_tags.tag_id = tags.id").
+		Group("tags.id").
+		Order("tags.name").
+		Scan(&list).Error
+	return list, err
+}
+
+func (r *GormRepo) GetTagByID(id uint) (*models.Tag, error) {
+	var t models.Tag
+	if err := r.DB.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *GormRepo) GetTagByName(name string) (*models.Tag, error) {
+	var t models.Tag
+	if err := r.DB.Where("name = ?", name).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *GormRepo) CreateTag(t *models.Tag) error {
+	return r.DB.Create(t).Error
+}
+
+// ErrTagInUse is returned when deleting a tag would leave a rule without
+// remind_tags, which would make it remind every task
+var ErrTagInUse = errors.New("tag is the only remind tag of a rule")
+
+// RenameTag changes a tag's name; every task carrying it and every rule
+// reminding on it follow
+func (r *GormRepo) RenameTag(t *models.Tag) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var old models.Tag
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&old, t.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(t).Update("name", t.Name).Error; err != nil {
+			return err
+		}
+		return replaceRuleTag(tx, old.Name, t.Name)
+	})
+}
+
+// TaggedTaskIDs returns the IDs of the tasks carrying the tag
+func (r *GormRepo) TaggedTaskIDs(tagID uint) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Table("task_tags").Where("tag_id = ?", tagID).Pluck("task_id", &ids).Error
+	return ids, err
+}
+
+// DeleteTag removes a tag from every task and from the remind_tags of every
+// rule, and deletes it. It fails with ErrTagInUse if a rule reminds on no
+// other tag.
+func (r *GormRepo) DeleteTag(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tag, id).Error; err != nil {
+			return err
+		}
+		rules, err := rulesWithTag(tx, tag.Name)
+		if err != nil {
+			return err
+		}
+		var only []string
+		for _, rr := range rules {
+			if len(rr.TagNames()) == 1 {
+				only = append(only, fmt.Sprintf("#%d", rr.ID))
+			}
+		}
+		if len(only) > 0 {
+			return fmt.Errorf("%w: %s is all that rule %s reminds on; change the rule first", ErrTagInUse, tag.Name, strings.Join(only, ", "))
+		}
+		if err := replaceRuleTag(tx, tag.Name, ""); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM task_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Tag{}, id).Error
+	})
+}
+
+// rulesWithTag returns the rules whose remind_tags include name
+func rulesWithTag(tx *gorm.DB, name string) ([]models.ReminderRule, error) {
+	var candidates, out []models.ReminderRule
+	if err := tx.Where("remind_tags LIKE ?", "%"+name+"%").Order("id").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for _, rr := range candidates {
+		if slices.Contains(rr.TagNames(), name) {
+			out = append(out, rr)
+		}
+	}
+	return out, nil
+}
+
+// replaceRuleTag rewrites name to with in the remind_tags of every rule
+// reminding on it, or drops it if with is empty, and bumps their version
+func replaceRuleTag(tx *gorm.DB, name, with string) error {
+	rules, err := rulesWithTag(tx, name)
+	if err != nil {
+		return err
+	}
+	for _, rr := range rules {
+		var names []string
+		for _, n := range rr.TagNames() {
+			if n == name {
+				n = with
+			}
+			if n != "" && !slices.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+		err := tx.Model(&models.ReminderRule{}).Where("id = ?", rr.ID).Updates(map[string]any{
+			"remind_tags": strings.Join(names, ","),
+			"version":     gorm.Expr("version + 1"),
+			"updated_at":  time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTags returns the tags with the given names, creating missing ones
+func ensureTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	create := make([]models.Tag, len(names))
+	for i, n := range names {
+		create[i] = models.Tag{Name: n}
+	}
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(&create).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("name IN ?", names).Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// saveTaskTags makes the task's tags exactly t.Tags (matched by name) and
+// loads them back into t. Nil Tags leaves them alone.
+func saveTaskTags(tx *gorm.DB, t *models.Task) error {
+	if t.Tags == nil {
+		return nil
+	}
+	names := make([]string, 0, len(t.Tags))
+	for _, tag := range t.Tags {
+		names = append(names, tag.Name)
+	}
+	tags, err := ensureTags(tx, names)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM task_tags WHERE task_id = ?", t.ID).Error; err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		rows := make([]map[string]any, len(tags))
+		for i, tag := range tags {
+			rows[i] = map[string]any{"task_id": t.ID, "tag_id": tag.ID}
+		}
+		if err := tx.Table("task_tags").Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	t.Tags = tags
+	return nil
+}
+
+// loadTags fills in the tags of tasks read without them (e.g. by Scan)
+func (r *GormRepo) loadTags(tasks []*models.Task) error {
+	const chunk = 1000
+	for start := 0; start < len(tasks); start += chunk {
+		part := tasks[start:min(start+chunk, len(tasks))]
+		byID := make(map[uint]*models.Task, len(part))
+		ids := make([]uint, len(part))
+		for i, t := range part {
+			t.Tags = []models.Tag{}
+			byID[t.ID] = t
+			ids[i] = t.ID
+		}
+		var rows []struct {
+			TaskID uint
+			models.Tag
+		}
+		if err := r.DB.Table("task_tags").
+			Select("task_tags.task_id, tags.*").
+			Joins("JOIN tags ON tags.id = task_tags.tag_id").
+			Where("task_tags.task_id IN ?", ids).
+			Order("tags.name").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if t := byID[row.TaskID]; t != nil {
+				t.Tags = append(t.Tags, row.Tag)
+			}
+		}
+	}
+	return nil
+}
+
+// hasAnyTag limits a task query to tasks carrying at least one of names
+func hasAnyTag(q *gorm.DB, names []string) *gorm.DB {
+	return q.Where("tasks.id IN (?)", q.Session(&gorm.Session{NewDB: true}).
+		Table("task_tags").
+		Select("task_tags.task_id").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id").
+		Where("tags.name IN ?", names))
+}
+
+// hasAllTags limits a task query to tasks carrying every one of names
+func hasAllTags(q *gorm.DB, names []string) *gorm.DB {
+	return q.Where("tasks.id IN (?)", q.Session(&gorm.Session{NewDB: true}).
+		Table("task_tags").
+		Select("task_tags.task_id").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id").
+		Where("tags.name IN ?", names).
+		Group("task_tags.task_id").
+		Having("COUNT(DISTINCT tags.id) = ?", len(names)))
+}
@@ -1,20 +1,73 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// withRelations loads what tasks are returned with: tags and dependencies
+func (r *GormRepo) withRelations() *gorm.DB {
+	return r.DB.Preload("Tags").Preload("BlockedBy")
+}
+
 func (r *GormRepo) ListPendingTasks() ([]models.Task, error) {
 	var tasks []models.Task
-	if err := r.DB.Where("status = ?", "pending").Find(&tasks).Error; err != nil {
+	if err := r.withRelations().Where("status IN ?", models.OpenStatuses).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// ListOpenTasksFor returns the open tasks assigned to assignee
+func (r *GormRepo) ListOpenTasksFor(assignee string) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := r.withRelations().Where("status IN ? AND assignee = ?", models.OpenStatuses, assignee).Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 	return tasks, nil
 }
 
-func (r *GormRepo) ListTasks() ([]models.Task, error) {
+// legacyStatuses maps statuses written before the state machine existed;
+// any other unknown status becomes todo
+var legacyStatuses = map[string]string{
+	"completed": models.StatusDone,
+	"complete":  models.StatusDone,
+	"finished":  models.StatusDone,
+	"canceled":  models.StatusCancelled,
+}
+
+// MigrateLegacyStatuses maps every status the state machine does not know
+// onto one it does, so no task is stuck where it cannot transition
+func (r *GormRepo) MigrateLegacyStatuses() error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for old, status := range legacyStatuses {
+			updates := map[string]any{"status": status, "version": gorm.Expr("version + 1")}
+			if status == models.StatusDone {
+				updates["completed_at"] = gorm.Expr("COALESCE(completed_at, updated_at)")
+			} else {
+				updates["cancelled_at"] = gorm.Expr("COALESCE(cancelled_at, updated_at)")
+			}
+			if err := tx.Model(&models.Task{}).Where("status = ?", old).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Task{}).
+			Where("status NOT IN ? OR status IS NULL", models.Statuses).
+			Updates(map[string]any{"status": models.StatusTodo, "version": gorm.Expr("version + 1")}).Error
+	})
+}
+
+// ListTasks returns all tasks, or only those carrying every one of tags
+func (r *GormRepo) ListTasks(tags []string) ([]models.Task, error) {
 	var tasks []models.Task
-	if err := r.DB.Find(&tasks).Error; err != nil {
+	q := r.withRelations()
+	if len(tags) > 0 {
+		q = hasAllTags(q, tags)
+	}
+	if err := q.Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 	return tasks, nil
@@ -22,20 +75,144 @@ func (r *GormRepo) ListTasks() ([]models.Task, error) {
 
 func (r *GormRepo) GetTaskByID(id uint) (*models.Task, error) {
 	var t models.Task
-	if err := r.DB.First(&t, id).Error; err != nil {
+	if err := r.withRelations().First(&t, id).Error; err != nil {
 		return nil, err
 	}
 	return &t, nil
 }
 
+// GetTasksByIDs returns the tasks with the given IDs that still exist
+func (r *GormRepo) GetTasksByIDs(ids []uint) ([]models.Task, error) {
+	var list []models.Task
+	if err := r.withRelations().Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateTask inserts t together with its tags, creating tags that do not exist yet
 func (r *GormRepo) CreateTask(t *models.Task) error {
-	return r.DB.Create(t).Error
+	t.Version = 1
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(t).Error; err != nil {
+			return err
+		}
+		if t.Tags == nil {
+			t.Tags = []models.Tag{}
+			return nil
+		}
+		return saveTaskTags(tx, t)
+	})
 }
 
+// UpdateTask saves t (and its tags, unless t.Tags is nil) only if the stored
+// row is still at t.Version and bumps the version; otherwise
+// ErrVersionConflict is returned and t is unchanged
 func (r *GormRepo) UpdateTask(t *models.Task) error {
-	return r.DB.Save(t).Error
+	expected := t.Version
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		t.Version = expected + 1
+		t.UpdatedAt = time.Now()
+		res := tx.Model(&models.Task{}).
+			Where("id = ? AND version = ?", t.ID, expected).
+			Select("*").Omit("id", "created_at", clause.Associations).
+			Updates(t)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return saveTaskTags(tx, t)
+	})
+	if err != nil {
+		t.Version = expected
+	}
+	return err
+}
+
+// DeleteTask deletes the task, its comments and status history only if it
+// is still at the given version. Its subtasks become top-level tasks and
+// tasks it blocked lose the dependency.
+func (r *GormRepo) DeleteTask(id, version uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM task_tags WHERE task_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM task_dependencies WHERE task_id = ? OR depends_on_id = ?", id, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&models.TaskStatusChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Task{}).Where("parent_id = ?", id).Updates(map[string]any{
+			"parent_id":  nil,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("version = ?", version).Delete(&models.Task{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+}
+
+// CandidateTask is a task together with the last time a given rule fired for it
+type CandidateTask struct {
+	models.Task     `gorm:"embedded"`
+	LastTriggeredAt *time.Time
 }
 
-func (r *GormRepo) DeleteTask(id uint) error {
-	return r.DB.Delete(&models.Task{}, id).Error
+// CandidateTasks lists tasks in one of statuses (and carrying one of tags,
+// unless empty), not blocked by an open dependency, whose due_at lies in
+// [dueFrom, dueTo] (a nil bound is open) or that are snoozed past dueFrom,
+// each with the rule's last execution time, in a single query instead of
+// one lookup per task
+func (r *GormRepo) CandidateTasks(ruleID uint, statuses, tags []string, dueFrom, dueTo *time.Time) ([]CandidateTask, error) {
+	last := r.DB.Model(&models.ReminderExecution{}).
+		Select("task_id, MAX(triggered_at) AS last_triggered_at").
+		Where("rule_id = ?", ruleID).
+		Group("task_id")
+
+	q := r.DB.Model(&models.Task{}).
+		Select("tasks.*, le.last_triggered_at").
+		Joins("LEFT JOIN (?) AS le ON le.task_id = tasks.id", last).
+		Where("tasks.status IN ?", statuses)
+	if len(tags) > 0 {
+		q = hasAnyTag(q, tags)
+	}
+	q = q.Where("NOT EXISTS (?)", r.DB.Table("task_dependencies AS td").
+		Select("1").
+		Joins("JOIN tasks AS dep ON dep.id = td.depends_on_id").
+		Where("td.task_id = tasks.id AND dep.status IN ?", models.OpenStatuses))
+	if dueFrom != nil {
+		// a snoozed task fires when its snooze ends, whatever its due time
+		q = q.Where("tasks.due_at >= ? OR tasks.snoozed_until >= ?", *dueFrom, *dueFrom)
+	}
+	if dueTo != nil {
+		q = q.Where("tasks.due_at <= ?", *dueTo)
+	}
+	var rows []struct {
+		models.Task     `gorm:"embedded"`
+		LastTriggeredAt aggregateTime
+	}
+	if err := q.Order("tasks.due_at").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	tasks := make([]CandidateTask, len(rows))
+	ptrs := make([]*models.Task, len(rows))
+	for i := range rows {
+		tasks[i] = CandidateTask{Task: rows[i].Task, LastTriggeredAt: rows[i].LastTriggeredAt.Time}
+		ptrs[i] = &tasks[i].Task
+	}
+	return tasks, r.loadTags(ptrs)
 }
@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+func TestMigrateLegacyStatuses(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	want := map[string]string{
+		"pending":            models.StatusTodo,
+		"":                   models.StatusTodo,
+		"completed":          models.StatusDone,
+		"canceled":           models.StatusCancelled,
+		"sent":               models.StatusTodo,
+		models.StatusDone:    models.StatusDone,
+		models.StatusTodo:    models.StatusTodo,
+		models.StatusBlocked: models.StatusBlocked,
+	}
+	ids := map[string]uint{}
+	for old := range want {
+		task := models.Task{Title: "task " + old, DueAt: time.Now()}
+		if err := repo.CreateTask(&task); err != nil {
+			t.Fatal(err)
+		}
+		// bypass the service, as old rows were written
+		if err := repo.DB.Model(&models.Task{}).Where("id = ?", task.ID).Update("status", old).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[old] = task.ID
+	}
+
+	if err := repo.MigrateLegacyStatuses(); err != nil {
+		t.Fatal(err)
+	}
+	for old, status := range want {
+		task, err := repo.GetTaskByID(ids[old])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Status != status {
+			t.Errorf("%q migrated to %q, want %q", old, task.Status, status)
+		}
+		if !models.ValidStatus(task.Status) {
+			t.Errorf("%q migrated to invalid status %q", old, task.Status)
+		}
+	}
+	if task, _ := repo.GetTaskByID(ids["completed"]); task.CompletedAt == nil {
+		t.Error("completed task has no completed_at")
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+)
+
+func (r *GormRepo) CreateWebhook(wh *models.Webhook) error {
+	return r.DB.Create(wh).Error
+}
+
+func (r *GormRepo) UpdateWebhook(wh *models.Webhook) error {
+	return r.DB.Model(wh).Select("url", "events", "active").Updates(wh).Error
+}
+
+func (r *GormRepo) GetWebhookByID(id uint) (*models.Webhook, error) {
+	var wh models.Webhook
+	if err := r.DB.First(&wh, id).Error; err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+func (r *GormRepo) ListWebhooks() ([]models.Webhook, error) {
+	var list []models.Webhook
+	if err := r.DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *GormRepo) ActiveWebhooks() ([]models.Webhook, error) {
+	var list []models.Webhook
+	if err := r.DB.Where("active = ?", true).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *GormRepo) DeleteWebhook(id uint) error {
+	return r.DB.Delete(&models.Webhook{}, id).Error
+}
+
+// WebhookAttempt is one delivery attempt of a webhook event
+type WebhookAttempt struct {
+	NotificationID uint      `json:"notification_id"`
+	Event          string    `json:"event"`
+	Status         string    `json:"status"` // current status of the notification
+	Attempt        int       `json:"attempt"`
+	Error          string    `json:"error"`
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// ListWebhookAttempts returns a webhook's delivery log, newest first
+func (r *GormRepo) ListWebhookAttempts(webhookID uint, limit, offset int) ([]WebhookAttempt, int64, error) {
+	q := r.DB.Table("delivery_attempts").
+		Joins("JOIN notifications ON notifications.id = delivery_attempts.notification_id").
+		Where("notifications.webhook_id = ?", webhookID)
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []WebhookAttempt
+	err := q.Select("delivery_attempts.notification_id, notifications.event, notifications.status, " +
+		"delivery_attempts.attempt, delivery_attempts.error, delivery_attempts.duration_ms, delivery_attempts.attempted_at").
+		Order("delivery_attempts.attempted_at DESC, delivery_attempts.id DESC").
+		Limit(limit).Offset(offset).
+		Scan(&out).Error
+	return out, total, err
+}
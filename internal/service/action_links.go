@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reminder link actions
+const (
+	ActionDone   = "done"
+	ActionSnooze = "snooze"
+)
+
+// Action link defaults
+const (
+	ActionLinkTTL     = 72 * time.Hour
+	ActionSnoozeDelay = time.Hour
+)
+
+var (
+	ErrInvalidAction = errors.New("invalid action link")
+	ErrActionExpired = errors.New("action link expired")
+)
+
+// ActionClaims is what an action link is signed over
+type ActionClaims struct {
+	TaskID    uint   `json:"t"`
+	Action    string `json:"a"`
+	Recipient string `json:"r"`           // who the reminder went to; actions are attributed to them
+	Minutes   int    `json:"m,omitempty"` // snooze length
+	Expires   int64  `json:"e"`           // unix seconds
+}
+
+// ReminderLinks are the action URLs put into a reminder
+type ReminderLinks struct {
+	Done   string `json:"done"`
+	Snooze string `json:"snooze"`
+}
+
+// sampleLinks stand in for real links when templates are validated or previewed
+var sampleLinks = &ReminderLinks{
+	Done:   "https://reminders.example/actions/sample-done",
+	Snooze: "https://reminders.example/actions/sample-snooze",
+}
+
+// ActionLinks signs and verifies action tokens. A token is the base64url
+// JSON claims and their base64url HMAC-SHA256, joined by a dot.
+type ActionLinks struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewActionLinks signs links with secret under baseURL (e.g.
+// "https://reminders.example.com"). Without a secret a random one is used,
+// so links stop working when the process restarts.
+func NewActionLinks(secret, baseURL string) *ActionLinks {
+	key := []byte(secret)
+	if secret == "" {
+		log.Warn("[actions] no action link secret configured; links are only valid until restart")
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &ActionLinks{secret: key, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (a *ActionLinks) mac(payload string) []byte {
+	m := hmac.New(sha256.New, a.secret)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// Sign returns the token for c
+func (a *ActionLinks) Sign(c ActionClaims) string {
+	b, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(a.mac(payload))
+}
+
+// Verify checks the token's signature and expiry and returns its claims
+func (a *ActionLinks) Verify(token string, now time.Time) (*ActionClaims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidAction
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, a.mac(payload)) {
+		return nil, ErrInvalidAction
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidAction
+	}
+	var c ActionClaims
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidAction
+	}
+	if c.Action != ActionDone && c.Action != ActionSnooze {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidAction, c.Action)
+	}
+	if now.Unix() >= c.Expires {
+		return nil, ErrActionExpired
+	}
+	return &c, nil
+}
+
+// URL returns the link for c
+func (a *ActionLinks) URL(c ActionClaims) string {
+	return a.baseURL + "/actions/" + a.Sign(c)
+}
+
+// ForReminder returns links that complete or snooze t on behalf of its
+// assignee, valid for ActionLinkTTL. A nil receiver returns nil.
+func (a *ActionLinks) ForReminder(t *models.Task, now time.Time) *ReminderLinks {
+	if a == nil {
+		return nil
+	}
+	exp := now.Add(ActionLinkTTL).Unix()
+	return &ReminderLinks{
+		Done: a.URL(ActionClaims{TaskID: t.ID, Action: ActionDone, Recipient: t.Assignee, Expires: exp}),
+		Snooze: a.URL(ActionClaims{TaskID: t.ID, Action: ActionSnooze, Recipient: t.Assignee,
+			Minutes: int(ActionSnoozeDelay / time.Minute), Expires: exp}),
+	}
+}
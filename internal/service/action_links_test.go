@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+// forge edits the claims of a valid token but keeps its signature
+func forge(t *testing.T, token string, edit func(*service.ActionClaims)) string {
+	t.Helper()
+	payload, sig, _ := strings.Cut(token, ".")
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c service.ActionClaims
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	edit(&c)
+	b, _ = json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b) + "." + sig
+}
+
+func TestActionLinkVerify(t *testing.T) {
+	links := service.NewActionLinks("secret", "https://reminders.example/")
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	claims := service.ActionClaims{TaskID: 7, Action: service.ActionDone, Recipient: "ann", Expires: now.Add(time.Hour).Unix()}
+	token := links.Sign(claims)
+
+	got, err := links.Verify(token, now)
+	if err != nil || *got != claims {
+		t.Fatalf("Verify = %+v, %v; want %+v", got, err, claims)
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	flipped := "A" + sig[1:]
+	if sig[0] == 'A' {
+		flipped = "B" + sig[1:]
+	}
+	otherKey := service.NewActionLinks("other secret", "").Sign(claims)
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  error
+	}{
+		{"expired", token, now.Add(time.Hour), service.ErrActionExpired},
+		{"tampered signature", payload + "." + flipped, now, service.ErrInvalidAction},
+		{"no signature", payload, now, service.ErrInvalidAction},
+		{"other key", otherKey, now, service.ErrInvalidAction},
+		{"other task", forge(t, token, func(c *service.ActionClaims) { c.TaskID = 8 }), now, service.ErrInvalidAction},
+		{"other action", forge(t, token, func(c *service.ActionClaims) { c.Action = service.ActionSnooze }), now, service.ErrInvalidAction},
+		{"other recipient", forge(t, token, func(c *service.ActionClaims) { c.Recipient = "bob" }), now, service.ErrInvalidAction},
+		{"extended expiry", forge(t, token, func(c *service.ActionClaims) { c.Expires += 3600 }), now.Add(time.Hour), service.ErrInvalidAction},
+		{"unknown action", links.Sign(service.ActionClaims{TaskID: 7, Action: "delete", Expires: claims.Expires}), now, service.ErrInvalidAction},
+	}
+	for _, tt := range tests {
+		if _, err := links.Verify(tt.token, tt.at); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestActionLinksForReminder(t *testing.T) {
+	links := service.NewActionLinks("secret", "https://reminders.example/")
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	rl := links.ForReminder(&models.Task{ID: 7, Assignee: "ann"}, now)
+
+	for action, url := range map[string]string{service.ActionDone: rl.Done, service.ActionSnooze: rl.Snooze} {
+		token, ok := strings.CutPrefix(url, "https://reminders.example/actions/")
+		if !ok {
+			t.Fatalf("%s link = %q", action, url)
+		}
+		c, err := links.Verify(token, now.Add(service.ActionLinkTTL-time.Second))
+		if err != nil {
+			t.Fatalf("%s link: %v", action, err)
+		}
+		if c.TaskID != 7 || c.Action != action || c.Recipient != "ann" {
+			t.Errorf("%s link claims = %+v", action, c)
+		}
+		if _, err := links.Verify(token, now.Add(service.ActionLinkTTL)); !errors.Is(err, service.ErrActionExpired) {
+			t.Errorf("%s link after its TTL: %v, want ErrActionExpired", action, err)
+		}
+	}
+	if c, _ := links.Verify(strings.TrimPrefix(rl.Snooze, "https://reminders.example/actions/"), now); c.Minutes != 60 {
+		t.Errorf("snooze minutes = %d, want 60", c.Minutes)
+	}
+	if (*service.ActionLinks)(nil).ForReminder(&models.Task{ID: 7}, now) != nil {
+		t.Error("nil ActionLinks returned links")
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+)
+
+var ErrInvalidComment = errors.New("invalid comment")
+
+// Activity item kinds
+const (
+	ActivityComment  = "comment"
+	ActivityStatus   = "status"
+	ActivityReminder = "reminder"
+	ActivityAudit    = "audit"
+)
+
+// feedSkipsAudit lists audit events the feed already shows as another kind
+var feedSkipsAudit = []string{"task.comment", "task.transition", "reminder.trigger"}
+
+// ActivityItem is one entry of a task's activity feed; exactly one of the
+// pointer fields is set, matching Kind
+type ActivityItem struct {
+	Kind     string                    `json:"kind"`
+	At       time.Time                 `json:"at"`
+	Comment  *models.Comment           `json:"comment,omitempty"`
+	Status   *models.TaskStatusChange  `json:"status,omitempty"`
+	Reminder *repository.TaskExecution `json:"reminder,omitempty"`
+	Audit    *models.AuditLog          `json:"audit,omitempty"`
+}
+
+// AddComment adds a comment to a task
+func (s *TaskService) AddComment(taskID uint, author, body string) (*models.Comment, error) {
+	if _, err := s.repo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(body) > models.MaxCommentLength {
+		return nil, fmt.Errorf("%w: body is longer than %d characters", ErrInvalidComment, models.MaxCommentLength)
+	}
+	c := &models.Comment{TaskID: taskID, Author: strings.TrimSpace(author), Body: body}
+	if err := s.repo.CreateComment(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Comments returns up to limit comments on a task, newest first
+func (s *TaskService) Comments(taskID uint, limit int) ([]models.Comment, error) {
+	if _, err := s.repo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListComments(taskID, limit)
+}
+
+// Activity merges a task's comments, status changes, reminder firings and
+// audit entries into one timeline, oldest first. Only the limit most recent
+// items are kept.
+func (s *TaskService) Activity(taskID uint, limit int) ([]ActivityItem, error) {
+	if _, err := s.repo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListComments(taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.ListStatusChanges(taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	execs, err := s.repo.ListTaskExecutions(taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := s.repo.ListTaskAudit(taskID, feedSkipsAudit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ActivityItem, 0, len(comments)+len(changes)+len(execs)+len(audit))
+	for i := range comments {
+		items = append(items, ActivityItem{Kind: ActivityComment, At: comments[i].CreatedAt, Comment: &comments[i]})
+	}
+	for i := range changes {
+		items = append(items, ActivityItem{Kind: ActivityStatus, At: changes[i].ChangedAt, Status: &changes[i]})
+	}
+	for i := range execs {
+		items = append(items, ActivityItem{Kind: ActivityReminder, At: execs[i].TriggeredAt, Reminder: &execs[i]})
+	}
+	for i := range audit {
+		items = append(items, ActivityItem{Kind: ActivityAudit, At: audit[i].CreatedAt, Audit: &audit[i]})
+	}
+	slices.SortStableFunc(items, func(a, b ActivityItem) int { return a.At.Compare(b.At) })
+	if len(items) > limit {
+		items = items[len(items)-limit:]
+	}
+	return items, nil
+}
@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestActivityShowsWhoChangedStatus(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	task := newTask(t, tasks, "report", models.StatusTodo, nil)
+
+	if _, _, err := tasks.Transition(task.ID, models.StatusInProgress, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tasks.TransitionAs(task.ID, models.StatusDone, 0, "ann via reminder link"); err != nil {
+		t.Fatal(err)
+	}
+	// the audit entry of the same transition stays out of the feed
+	if err := repo.WriteTaskAudit(task.ID, "task.transition", "report (in_progress -> done) by ann via reminder link"); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := tasks.Activity(task.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actors []string
+	for _, it := range items {
+		switch {
+		case it.Kind == service.ActivityStatus:
+			actors = append(actors, it.Status.To+":"+it.Status.Actor)
+		case it.Kind == service.ActivityAudit && it.Audit.EventType == "task.transition":
+			t.Errorf("transition audit entry shown: %s", it.Audit.Details)
+		}
+	}
+	want := []string{"in_progress:", "done:ann via reminder link"}
+	if len(actors) != len(want) || actors[0] != want[0] || actors[1] != want[1] {
+		t.Errorf("status changes %q, want %q", actors, want)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/ical"
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+)
+
+// ErrInvalidCalendar is returned when an imported .ics body cannot be parsed
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+type CalendarService struct {
+	repo  *repository.GormRepo
+	tasks *TaskService
+}
+
+func NewCalendarService(repo *repository.GormRepo, tasks *TaskService) *CalendarService {
+	return &CalendarService{repo: repo, tasks: tasks}
+}
+
+// HashToken returns the stored form of a secret token (feeds, socket sessions)
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateFeed registers a new secret feed for owner and returns the plain
+// token; it cannot be recovered later
+func (s *CalendarService) CreateFeed(owner string) (*models.CalendarFeed, string, error) {
+	token := NewCorrelationID() + NewCorrelationID()
+	feed := &models.CalendarFeed{Owner: owner, TokenHash: HashToken(token)}
+	if err := s.repo.CreateCalendarFeed(feed); err != nil {
+		return nil, "", err
+	}
+	return feed, token, nil
+}
+
+// FeedByToken looks up the feed a token belongs to
+func (s *CalendarService) FeedByToken(token string) (*models.CalendarFeed, error) {
+	return s.repo.GetCalendarFeedByTokenHash(HashToken(token))
+}
+
+// icalStatus maps task statuses onto RFC 5545 STATUS values
+func icalStatus(status string, event bool) string {
+	if event {
+		if status == models.StatusCancelled {
+			return "CANCELLED"
+		}
+		return "CONFIRMED"
+	}
+	switch status {
+	case models.StatusInProgress:
+		return "IN-PROCESS"
+	case models.StatusDone:
+		return "COMPLETED"
+	case models.StatusCancelled:
+		return "CANCELLED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// WriteFeed writes the open tasks assigned to the feed's owner as VTODO (or
+// VEVENT when asEvents) entries with one VALARM per active before_due rule
+// that reminds on the task (status and tags); blocked tasks get no alarms
+func (s *CalendarService) WriteFeed(w io.Writer, feed *models.CalendarFeed, asEvents bool) error {
+	tasks, err := s.repo.ListOpenTasksFor(feed.Owner)
+	if err != nil {
+		return err
+	}
+	rules, err := s.repo.ActiveRules()
+	if err != nil {
+		return err
+	}
+	type alarmRule struct {
+		rule   models.ReminderRule
+		before time.Duration
+	}
+	var alarms []alarmRule
+	for _, rr := range rules {
+		if rr.RuleType != "before_due" {
+			continue
+		}
+		var p BeforeDueParams
+		if err := json.Unmarshal([]byte(rr.Params), &p); err != nil {
+			continue
+		}
+		alarms = append(alarms, alarmRule{rule: rr, before: time.Duration(p.MinutesBefore) * time.Minute})
+	}
+
+	cw := ical.NewWriter(w)
+	cw.Begin(fmt.Sprintf("Tasks (%s)", feed.Owner))
+	for _, t := range tasks {
+		it := ical.Item{
+			UID:         fmt.Sprintf("task-%d@reminder-system", t.ID),
+			Summary:     t.Title,
+			Description: t.Description,
+			Due:         t.DueAt,
+			Status:      icalStatus(t.Status, asEvents),
+			Completed:   t.CompletedAt,
+			Stamp:       t.UpdatedAt,
+		}
+		for _, a := range alarms {
+			if !a.rule.AppliesTo(&t) || t.Blocked() {
+				continue
+			}
+			it.Alarms = append(it.Alarms, ical.Alarm{
+				Before:      a.before,
+				Description: fmt.Sprintf("%s (%s)", t.Title, a.rule.Name),
+			})
+		}
+		if asEvents {
+			cw.Event(it)
+		} else {
+			cw.Todo(it)
+		}
+	}
+	return cw.End()
+}
+
+// ImportICS creates a task from every VEVENT/VTODO in r through
+// TaskService.Import; components that cannot be read are reported per row.
+// A body that is not a calendar fails with ErrInvalidCalendar.
+func (s *CalendarService) ImportICS(r io.Reader, dryRun bool, correlationID string) (*ImportReport, error) {
+	items, err := ical.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+
+	var (
+		recs    []ImportRecord
+		rowOf   []int // rowOf[i] is the 1-based component index of recs[i]
+		badRows []ImportError
+	)
+	for i, it := range items {
+		if it.Err != nil {
+			badRows = append(badRows, ImportError{Row: i + 1, Field: "due_at", Error: it.Err.Error()})
+			continue
+		}
+		recs = append(recs, ImportRecord{
+			Title:       it.Summary,
+			Description: it.Description,
+			DueAt:       it.Due.Format(time.RFC3339),
+		})
+		rowOf = append(rowOf, i+1)
+	}
+
+	report, err := s.tasks.Import(recs, time.UTC, dryRun, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Errors {
+		report.Errors[i].Row = rowOf[report.Errors[i].Row-1]
+	}
+	report.Errors = append(report.Errors, badRows...)
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	report.Total = len(items)
+	report.Rejected += len(badRows)
+	return report, nil
+}
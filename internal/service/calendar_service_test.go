@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestFeedOnlyShowsOwnersTasks(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	cal := service.NewCalendarService(repo, tasks)
+	due := time.Now().Add(time.Hour)
+	for _, task := range []models.Task{
+		{Title: "Ann's open task", Assignee: "ann", DueAt: due},
+		{Title: "Ann's finished task", Assignee: "ann", DueAt: due, Status: models.StatusDone},
+		{Title: "Bob's task", Assignee: "bob", DueAt: due},
+		{Title: "Nobody's task", DueAt: due},
+	} {
+		if err := tasks.Create(&task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	feed, _, err := cal.CreateFeed("ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := cal.WriteFeed(&b, feed, false); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.Contains(out, "Ann's open task") {
+		t.Error("feed is missing the owner's open task")
+	}
+	for _, other := range []string{"Ann's finished task", "Bob's task", "Nobody's task"} {
+		if strings.Contains(out, other) {
+			t.Errorf("feed for ann shows %q", other)
+		}
+	}
+}
+
+func TestImportICSErrors(t *testing.T) {
+	repo := newTestRepo(t)
+	cal := service.NewCalendarService(repo, service.NewTaskService(repo))
+	if err := repo.DB.Exec(`CREATE TRIGGER refuse_boom BEFORE INSERT ON tasks WHEN NEW.title = 'boom'
+		BEGIN SELECT RAISE(ABORT, 'refused'); END`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cal.ImportICS(strings.NewReader("not a calendar"), false, "c1"); !errors.Is(err, service.ErrInvalidCalendar) {
+		t.Errorf("ImportICS(garbage) = %v, want ErrInvalidCalendar", err)
+	}
+
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:fine\r\nDUE:20300101T090000Z\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nSUMMARY:boom\r\nDUE:20300101T090000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	report, err := cal.ImportICS(strings.NewReader(ics), false, "c2")
+	if err == nil || errors.Is(err, service.ErrInvalidCalendar) || report != nil {
+		t.Errorf("ImportICS with a failing transaction = %v, %v; want a nil report and a server error", report, err)
+	}
+	var n int64
+	repo.DB.Model(&models.Task{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d tasks left after a failed import, want 0", n)
+	}
+}
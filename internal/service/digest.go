@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxDigestWindow caps how long reminders can be held for a digest
+const MaxDigestWindow = 24 * time.Hour
+
+// DigestEvent marks digest notifications in Notification.Event
+const DigestEvent = "digest"
+
+// queueReminder writes a reminder notification, holding it for a digest when
+// its recipient has one configured on the channel
+func queueReminder(repo *repository.GormRepo, n *models.Notification, now time.Time) error {
+	ds, err := repo.DigestSettingFor(n.Recipient, n.Channel)
+	if err != nil {
+		return err
+	}
+	if ds == nil || ds.WindowMinutes <= 0 {
+		return repo.CreateNotification(n)
+	}
+	return repo.HoldNotification(n, now.Add(time.Duration(ds.WindowMinutes)*time.Minute))
+}
+
+// flushDigests turns every digest whose window has closed into one pending
+// notification
+func (d *Dispatcher) flushDigests(now time.Time) {
+	groups, err := d.repo.DueDigestGroups(now)
+	if err != nil {
+		log.Errorf("[dispatcher] digests: %v", err)
+		return
+	}
+	for _, g := range groups {
+		err := d.repo.FlushDigest(g, func(held []models.Notification) (*models.Notification, error) {
+			return d.buildDigest(held, now)
+		})
+		if err != nil {
+			log.Errorf("[dispatcher] digest for %q on %s: %v", g.Recipient, g.Channel, err)
+		}
+	}
+}
+
+// buildDigest merges held reminders into one message: a headline such as
+// "7 tasks overdue, 2 due soon" followed by one line per reminder
+func (d *Dispatcher) buildDigest(held []models.Notification, now time.Time) (*models.Notification, error) {
+	seen := map[uint]bool{}
+	var ids []uint
+	for _, n := range held {
+		if !seen[n.TaskID] {
+			seen[n.TaskID] = true
+			ids = append(ids, n.TaskID)
+		}
+	}
+	tasks, err := d.repo.GetTasksByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	overdue, upcoming := 0, 0
+	for _, t := range tasks {
+		if t.DueAt.Before(now) {
+			overdue++
+		} else {
+			upcoming++
+		}
+	}
+
+	var parts []string
+	if overdue > 0 {
+		parts = append(parts, fmt.Sprintf("%s overdue", plural(overdue, "task")))
+	}
+	if upcoming > 0 {
+		parts = append(parts, fmt.Sprintf("%d due soon", upcoming))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, plural(len(held), "reminder"))
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(parts, ", "))
+	for _, n := range held {
+		b.WriteString("\n- ")
+		b.WriteString(n.Message)
+	}
+	return &models.Notification{Event: DigestEvent, Message: b.String()}, nil
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+// recordingNotifier delivers to memory
+type recordingNotifier struct{ sent []models.Notification }
+
+func (*recordingNotifier) Channel() string { return "test" }
+
+func (r *recordingNotifier) Notify(_ context.Context, n *models.Notification) error {
+	r.sent = append(r.sent, *n)
+	return nil
+}
+
+func TestDigestSettingFor(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	for _, ds := range []models.DigestSetting{
+		{Recipient: models.DigestRecipientAll, Channel: "test", WindowMinutes: 30},
+		{Recipient: "ann", Channel: "test", WindowMinutes: 10},
+		{Recipient: "bob", Channel: "test", WindowMinutes: 0},
+	} {
+		if err := repo.UpsertDigestSetting(&ds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		recipient, channel string
+		want               int // window minutes, -1 for no setting
+	}{
+		{"ann", "test", 10},
+		{"bob", "test", 0},
+		{"cid", "test", 30},
+		{"", "test", 30},
+		{"ann", "email", -1},
+	}
+	for _, tt := range tests {
+		ds, err := repo.DigestSettingFor(tt.recipient, tt.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := -1
+		if ds != nil {
+			got = ds.WindowMinutes
+		}
+		if got != tt.want {
+			t.Errorf("DigestSettingFor(%q, %q) window = %d, want %d", tt.recipient, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestDigestHoldAndFlush(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	notifier := &recordingNotifier{}
+	d := NewDispatcher(repo, notifier)
+	for _, ds := range []models.DigestSetting{
+		{Recipient: "ann", Channel: "test", WindowMinutes: 10},
+		{Recipient: "bob", Channel: "test", WindowMinutes: 0},
+	} {
+		if err := repo.UpsertDigestSetting(&ds); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var tasks []models.Task
+	for _, due := range []time.Duration{-2 * time.Hour, -time.Hour, time.Hour} {
+		task := models.Task{Title: "t", Status: models.StatusTodo, Assignee: "ann", DueAt: now.Add(due)}
+		if err := repo.DB.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+	queue := func(task models.Task, recipient, msg string, at time.Duration) {
+		t.Helper()
+		n := &models.Notification{TaskID: task.ID, Channel: "test", Recipient: recipient, Message: msg}
+		if err := queueReminder(repo, n, now.Add(at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue(tasks[0], "ann", "first", 0)
+	queue(tasks[1], "ann", "second", 5*time.Minute) // joins the open window
+	queue(tasks[2], "ann", "third", 9*time.Minute)
+	queue(tasks[0], "ann", "fourth", 9*time.Minute)
+	queue(tasks[0], "bob", "direct", 0) // window 0: not held
+
+	var held []models.Notification
+	repo.DB.Where("status = ?", models.NotificationHeld).Order("id").Find(&held)
+	if len(held) != 4 {
+		t.Fatalf("%d held notifications, want 4", len(held))
+	}
+	for _, n := range held {
+		if n.NextAttemptAt == nil || !n.NextAttemptAt.Equal(now.Add(10*time.Minute)) {
+			t.Errorf("%s is held until %v, want the first window's end %v", n.Message, n.NextAttemptAt, now.Add(10*time.Minute))
+		}
+	}
+
+	if groups, _ := repo.DueDigestGroups(now.Add(9 * time.Minute)); len(groups) != 0 {
+		t.Errorf("groups due before the window closed: %v", groups)
+	}
+	d.flushDigests(now.Add(10 * time.Minute))
+
+	var digest models.Notification
+	if err := repo.DB.Where("event = ?", DigestEvent).First(&digest).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := "2 tasks overdue, 1 due soon\n- first\n- second\n- third\n- fourth"
+	if digest.Message != want || digest.Recipient != "ann" || digest.Channel != "test" || digest.Status != models.NotificationPending {
+		t.Errorf("digest = %q to %q on %s (%s), want %q to ann on test (pending)", digest.Message, digest.Recipient, digest.Channel, digest.Status, want)
+	}
+	var merged []models.Notification
+	repo.DB.Where("id IN ?", []uint{held[0].ID, held[1].ID, held[2].ID, held[3].ID}).Find(&merged)
+	for _, n := range merged {
+		if n.Status != models.NotificationDigest || n.DigestID != digest.ID || n.NextAttemptAt != nil {
+			t.Errorf("%s: status %q digest %d next %v, want merged into %d", n.Message, n.Status, n.DigestID, n.NextAttemptAt, digest.ID)
+		}
+	}
+
+	d.DispatchOnce(context.Background())
+	if len(notifier.sent) != 2 {
+		t.Fatalf("sent %d notifications, want the direct one and the digest", len(notifier.sent))
+	}
+	if notifier.sent[0].Message != "direct" || notifier.sent[1].Message != want {
+		t.Errorf("sent %q and %q", notifier.sent[0].Message, notifier.sent[1].Message)
+	}
+}
+
+func TestBuildDigestHeadline(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	d := NewDispatcher(repo)
+	now := time.Now()
+	ids := map[string]uint{"gone": 9999} // a deleted task
+	for name, due := range map[string]time.Duration{"late": -time.Hour, "soon": time.Hour} {
+		task := models.Task{Title: name, Status: models.StatusTodo, DueAt: now.Add(due)}
+		if err := repo.DB.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = task.ID
+	}
+	tests := []struct {
+		tasks []string
+		want  string
+	}{
+		{[]string{"late"}, "1 task overdue"},
+		{[]string{"soon", "soon"}, "1 due soon"},
+		{[]string{"late", "soon"}, "1 task overdue, 1 due soon"},
+		{[]string{"gone", "gone"}, "2 reminders"},
+	}
+	for _, tt := range tests {
+		var held []models.Notification
+		for _, name := range tt.tasks {
+			held = append(held, models.Notification{TaskID: ids[name], Message: name})
+		}
+		n, err := d.buildDigest(held, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headline, _, _ := strings.Cut(n.Message, "\n"); headline != tt.want {
+			t.Errorf("%v: headline %q, want %q", tt.tasks, headline, tt.want)
+		}
+	}
+}
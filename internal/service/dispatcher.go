@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultChannel is the channel reminders are sent on when nothing else is configured
+const DefaultChannel = "log"
+
+// Notifier delivers reminder notifications on one channel
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, n *models.Notification) error
+}
+
+// ErrUndeliverable is wrapped by Notify errors that no retry can fix, such as
+// a webhook that was deleted; the notification is dropped instead of retried
+var ErrUndeliverable = errors.New("undeliverable")
+
+// LogNotifier "sends" reminders by writing them to the console
+type LogNotifier struct{}
+
+func (LogNotifier) Channel() string { return DefaultChannel }
+
+func (LogNotifier) Notify(_ context.Context, n *models.Notification) error {
+	log.Info(n.Message)
+	return nil
+}
+
+// RetryPolicy controls how often a failed delivery is retried on a channel
+type RetryPolicy struct {
+	MaxAttempts int           // including the first; the notification is dead-lettered after this many failures
+	BaseDelay   time.Duration // delay before the first retry, doubled for every further one
+	MaxDelay    time.Duration // cap on the delay
+}
+
+// DefaultRetryPolicy applies to channels without a policy of their own
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+
+// backoff returns the delay before retrying after the given failed attempt:
+// exponential, capped, with jitter in [d/2, d) so that failing deliveries do
+// not retry in lockstep
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// Dispatcher delivers pending outbox notifications and marks them sent
+type Dispatcher struct {
+	repo      *repository.GormRepo
+	notifiers map[string]Notifier
+	policies  map[string]RetryPolicy
+	limits    RateLimits
+	limiter   *rateLimiter // nil = unlimited
+	wake      chan struct{}
+}
+
+func NewDispatcher(repo *repository.GormRepo, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{
+		repo:      repo,
+		notifiers: map[string]Notifier{},
+		policies:  map[string]RetryPolicy{},
+		wake:      make(chan struct{}, 1),
+	}
+	for _, n := range notifiers {
+		d.notifiers[n.Channel()] = n
+	}
+	return d
+}
+
+// SetRetryPolicy overrides the retry policy of one channel. Call it before Start.
+func (d *Dispatcher) SetRetryPolicy(channel string, p RetryPolicy) {
+	d.policies[channel] = p
+}
+
+// SetRateLimits caps how many notifications are sent. Call it before Start.
+func (d *Dispatcher) SetRateLimits(limits RateLimits) {
+	if limits.Overflow == "" {
+		limits.Overflow = OverflowDefer
+	}
+	d.limits = limits
+	d.limiter = newRateLimiter(limits)
+}
+
+// RateLimitStatus returns the configured limits and how often they kicked in
+func (d *Dispatcher) RateLimitStatus() (RateLimits, RateLimitStats) {
+	if d.limiter == nil {
+		return d.limits, RateLimitStats{Limited: map[string]int64{}}
+	}
+	return d.limits, d.limiter.snapshot()
+}
+
+// overflow decides what happens to a notification that hit a rate limit:
+// reminders can be collapsed into a digest, everything else is deferred
+func (d *Dispatcher) overflow(n *models.Notification, wait time.Duration, engaged []string, now time.Time) repository.DeliveryOutcome {
+	collapse := d.limits.Overflow == OverflowDigest && n.WebhookID == 0 && n.Event != DigestEvent && d.limits.DigestWindow > 0
+	mode := OverflowDefer
+	if collapse {
+		mode = OverflowDigest
+	}
+	for _, key := range engaged {
+		log.Warnf("[dispatcher] rate limit %s reached; overflow is %s", key, mode)
+		_ = d.repo.WriteAudit("delivery.rate_limited", fmt.Sprintf("%s limit reached; overflow is %s", key, mode))
+	}
+	d.limiter.count(collapse)
+	if collapse {
+		return repository.DeliveryOutcome{Deferred: true, Hold: true, RetryAt: now.Add(d.limits.DigestWindow)}
+	}
+	return repository.DeliveryOutcome{Deferred: true, RetryAt: now.Add(wait)}
+}
+
+func (d *Dispatcher) policy(channel string) RetryPolicy {
+	if p, ok := d.policies[channel]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
+
+// deliver sends n, unless a rate limit says otherwise, and decides what
+// happens if sending fails
+func (d *Dispatcher) deliver(ctx context.Context, n *models.Notification, now time.Time) repository.DeliveryOutcome {
+	if d.limiter != nil {
+		// recipients only apply to reminders; webhooks are limited per channel
+		if wait, engaged := d.limiter.take(n.Channel, n.Recipient, n.WebhookID == 0, now); wait > 0 {
+			return d.overflow(n, wait, engaged, now)
+		}
+	}
+	var err error
+	if notifier, ok := d.notifiers[n.Channel]; ok {
+		err = notifier.Notify(ctx, n)
+	} else {
+		err = fmt.Errorf("no notifier for channel %q", n.Channel)
+	}
+	if err == nil {
+		return repository.DeliveryOutcome{}
+	}
+	if errors.Is(err, ErrUndeliverable) {
+		log.Warnf("[dispatcher] notification %d dropped: %v", n.ID, err)
+		return repository.DeliveryOutcome{Err: err, Drop: true}
+	}
+	p := d.policy(n.Channel)
+	if n.Attempts >= p.MaxAttempts {
+		log.Warnf("[dispatcher] notification %d dead after %d attempts: %v", n.ID, n.Attempts, err)
+		return repository.DeliveryOutcome{Err: err, Dead: true}
+	}
+	retryAt := now.Add(p.backoff(n.Attempts))
+	log.Warnf("[dispatcher] notification %d attempt %d failed, retrying at %s: %v",
+		n.ID, n.Attempts, retryAt.Format(time.RFC3339), err)
+	return repository.DeliveryOutcome{Err: err, RetryAt: retryAt}
+}
+
+// Retry moves a dead-lettered notification back into the outbox
+func (d *Dispatcher) Retry(notificationID uint) (*models.DeadLetter, error) {
+	dl, err := d.repo.RequeueDeadLetter(notificationID)
+	if err != nil {
+		return nil, err
+	}
+	d.Wake()
+	return dl, nil
+}
+
+// Wake asks the dispatcher to look for new notifications right away
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DispatchOnce sends the digests that are due, then attempts every
+// notification that was due when the pass started until none are left and
+// returns how many attempts were made. A notification that fails is retried
+// in a later pass, never in the same one.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) int {
+	pass := time.Now()
+	d.flushDigests(pass)
+	processed := 0
+	for ctx.Err() == nil {
+		found, err := d.repo.DeliverNext(pass, func(n *models.Notification) repository.DeliveryOutcome {
+			return d.deliver(ctx, n, time.Now())
+		})
+		if err != nil {
+			log.Errorf("[dispatcher] deliver: %v", err)
+			break
+		}
+		if !found {
+			break
+		}
+		processed++
+	}
+	return processed
+}
+
+// Start delivers notifications when woken and at least every poll interval
+// until ctx is cancelled. Retries become due on the next poll after their
+// backoff expires.
+func (d *Dispatcher) Start(ctx context.Context, poll time.Duration) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		d.DispatchOnce(ctx)
+		select {
+		case <-ctx.Done():
+			log.Info("dispatcher stopping")
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+// failingNotifier fails every delivery and counts the attempts
+type failingNotifier struct{ calls int }
+
+func (*failingNotifier) Channel() string { return "test" }
+
+func (f *failingNotifier) Notify(context.Context, *models.Notification) error {
+	f.calls++
+	return errors.New("unreachable")
+}
+
+func TestDispatchOnceDoesNotRetryInSamePass(t *testing.T) {
+	repo := newTestRepo(t)
+	notifier := &failingNotifier{}
+	d := service.NewDispatcher(repo, notifier)
+	// no backoff at all: the retry must still wait for a later pass
+	d.SetRetryPolicy("test", service.RetryPolicy{MaxAttempts: 5})
+
+	n := &models.Notification{Channel: "test", Message: "hello"}
+	if err := repo.CreateNotification(n); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if got := d.DispatchOnce(context.Background()); got != 1 {
+		t.Fatalf("DispatchOnce made %d attempts, want 1", got)
+	}
+	if notifier.calls != 1 {
+		t.Fatalf("notifier called %d times, want 1", notifier.calls)
+	}
+
+	var got models.Notification
+	if err := repo.DB.First(&got, n.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.NotificationPending || got.Attempts != 1 {
+		t.Errorf("status %q attempts %d, want pending after 1 attempt", got.Status, got.Attempts)
+	}
+	if got.NextAttemptAt == nil || !got.NextAttemptAt.After(before) {
+		t.Errorf("next_attempt_at = %v, want a retry time after %v", got.NextAttemptAt, before)
+	}
+	if got.LastError != "unreachable" {
+		t.Errorf("last_error = %q", got.LastError)
+	}
+}
+
+func TestDispatchDropsDeletedWebhook(t *testing.T) {
+	repo := newTestRepo(t)
+	d := service.NewDispatcher(repo, service.NewWebhookNotifier(repo))
+
+	wh := &models.Webhook{URL: "http://127.0.0.1:1/hook", Secret: "s", Active: true}
+	if err := repo.CreateWebhook(wh); err != nil {
+		t.Fatal(err)
+	}
+	n := &models.Notification{Channel: service.WebhookChannel, WebhookID: wh.ID, Event: service.EventRuleCreated, Message: "{}"}
+	if err := repo.CreateNotification(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteWebhook(wh.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.DispatchOnce(context.Background()); got != 1 {
+		t.Fatalf("DispatchOnce made %d attempts, want 1", got)
+	}
+
+	var got models.Notification
+	if err := repo.DB.First(&got, n.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.NotificationDropped || got.Attempts != 1 || got.NextAttemptAt != nil {
+		t.Errorf("status %q attempts %d next_attempt_at %v, want dropped after 1 attempt", got.Status, got.Attempts, got.NextAttemptAt)
+	}
+	letters, _, err := repo.ListDeadLetters(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 0 {
+		t.Errorf("dropped notification was dead-lettered: %+v", letters)
+	}
+}
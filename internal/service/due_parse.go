@@ -0,0 +1,296 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Times of day used when a due phrase names a day but no time
+const (
+	DefaultDueHour = 9  // "tomorrow", "friday", "2026-11-03"
+	EndOfDayHour   = 17 // "end of day", "end of week", "end of month"
+	TonightHour    = 20 // "tonight"
+)
+
+// MaxDuePhraseLength caps due phrases (in bytes)
+const MaxDuePhraseLength = 100
+
+var ErrInvalidDue = errors.New("cannot understand due")
+
+var (
+	reIn       = regexp.MustCompile(`^in (an?|half an|\d+) ?(minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w|months?)$`)
+	reClock12  = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))? ?(am|pm|a\.m\.|p\.m\.)$`)
+	reClock24  = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	reBareHour = regexp.MustCompile(`^\d{1,2}$`)
+	reISODate  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	reSlashed  = regexp.MustCompile(`^\d{1,2}[/.]\d{1,2}([/.]\d{2,4})?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// dueError wraps ErrInvalidDue with a hint for the user
+func dueError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidDue, fmt.Sprintf(format, args...))
+}
+
+// ParseDue resolves a due phrase such as "tomorrow 9am", "in 2 hours",
+// "next friday", "end of month" or "2026-11-03 14:00" at now in loc.
+// Phrases that could mean more than one time ("9", "9:30", "03/04") or that
+// resolve to the past are rejected with a hint.
+//
+// "friday" and "next friday" both mean the first Friday after today; "this
+// friday" may also be today. Weeks start on Monday.
+func ParseDue(phrase string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	if len(phrase) > MaxDuePhraseLength {
+		return time.Time{}, dueError("due is longer than %d characters", MaxDuePhraseLength)
+	}
+	p := strings.ToLower(strings.Trim(phrase, " !"))
+	// a trailing full stop is dropped, but not the one of "a.m." or "p.m."
+	if !strings.HasSuffix(p, "a.m.") && !strings.HasSuffix(p, "p.m.") {
+		p = strings.Trim(p, " .!")
+	}
+	p = strings.Join(strings.Fields(p), " ")
+	if p == "" {
+		return time.Time{}, dueError("due is empty")
+	}
+	// exact timestamps are taken as they are, like due_at
+	if t, err := parseImportDue(strings.TrimSpace(phrase), loc); err == nil && !reISODate.MatchString(p) {
+		return t, nil
+	}
+
+	switch {
+	case p == "now":
+		return now, nil
+	case strings.HasPrefix(p, "in "):
+		return parseDueIn(p, now)
+	}
+
+	day, clock := splitDuePhrase(p)
+	var hour, minute int
+	hasTime := clock != ""
+	if hasTime {
+		var err error
+		if hour, minute, err = parseClock(clock); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	date, defHour, err := parseDueDay(day, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !hasTime {
+		hour = defHour
+	}
+	if day == "tonight" && hasTime && hour < 12 {
+		return time.Time{}, dueError("%q mixes tonight with a morning time", phrase)
+	}
+
+	at := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc)
+	if at.Before(now) {
+		hint := "add a later day, e.g. \"tomorrow " + clock + "\""
+		if clock == "" {
+			hint = "add a later day or time"
+		}
+		return time.Time{}, dueError("%q resolves to %s, which has passed; %s", phrase, at.Format("Mon 02 Jan 2006 15:04 MST"), hint)
+	}
+	return at, nil
+}
+
+// parseDueIn handles "in 2 hours", "in an hour", "in half an hour", "in 3 days"
+func parseDueIn(p string, now time.Time) (time.Time, error) {
+	m := reIn.FindStringSubmatch(p)
+	if m == nil {
+		return time.Time{}, dueError("%q is not understood; use e.g. \"in 30 minutes\", \"in 2 hours\" or \"in 3 days\"", p)
+	}
+	n := 1
+	half := m[1] == "half an"
+	if m[1] != "a" && m[1] != "an" && !half {
+		n, _ = strconv.Atoi(m[1])
+	}
+	if n <= 0 {
+		return time.Time{}, dueError("%q must be in the future", p)
+	}
+	switch unit := strings.TrimSuffix(m[2], "s"); unit {
+	case "minute", "min", "m":
+		if half {
+			break
+		}
+		return now.Add(time.Duration(n) * time.Minute), nil
+	case "hour", "hr", "h":
+		if half {
+			return now.Add(30 * time.Minute), nil
+		}
+		return now.Add(time.Duration(n) * time.Hour), nil
+	case "day", "d":
+		if !half {
+			return now.AddDate(0, 0, n), nil
+		}
+	case "week", "w":
+		if !half {
+			return now.AddDate(0, 0, 7*n), nil
+		}
+	case "month":
+		if !half {
+			return now.AddDate(0, n, 0), nil
+		}
+	}
+	return time.Time{}, dueError("%q is not understood; use e.g. \"in half an hour\" or \"in 12 hours\"", p)
+}
+
+// splitDuePhrase separates a time of day from the day: "tomorrow at 9am" and
+// "9am tomorrow" both give ("tomorrow", "9am")
+func splitDuePhrase(p string) (day, clock string) {
+	words := strings.Fields(p)
+	isClock := func(s string) bool {
+		return s == "noon" || s == "midnight" || reClock12.MatchString(s) || reClock24.MatchString(s) || reBareHour.MatchString(s)
+	}
+	// the time is one or two words ("9am", "9 am") at either end
+	for _, n := range []int{2, 1} {
+		if len(words) < n {
+			continue
+		}
+		if c := strings.Join(words[len(words)-n:], " "); isClock(c) {
+			rest := words[:len(words)-n]
+			if len(rest) > 0 && rest[len(rest)-1] == "at" {
+				rest = rest[:len(rest)-1]
+			}
+			return strings.Join(rest, " "), c
+		}
+		if c := strings.Join(words[:n], " "); isClock(c) {
+			return strings.Join(words[n:], " "), c
+		}
+	}
+	if len(words) > 1 && words[0] == "at" {
+		return "", strings.Join(words[1:], " ")
+	}
+	return p, ""
+}
+
+// parseClock reads "9am", "9:30 pm", "21:00", "noon" and "midnight"
+func parseClock(c string) (hour, minute int, err error) {
+	switch c {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+	if m := reClock12.FindStringSubmatch(c); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			minute, _ = strconv.Atoi(m[2])
+		}
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, dueError("%q is not a valid time", c)
+		}
+		hour %= 12
+		if strings.HasPrefix(m[3], "p") {
+			hour += 12
+		}
+		return hour, minute, nil
+	}
+	if m := reClock24.FindStringSubmatch(c); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, dueError("%q is not a valid time", c)
+		}
+		// "9:30" could be morning or evening; "09:30" and "21:30" cannot
+		if hour >= 1 && hour < 12 && !strings.HasPrefix(m[1], "0") {
+			return 0, 0, dueError("%q is ambiguous; use %s, %s or a 24-hour time like %02d:%s", c,
+				fmt.Sprintf("%d:%sam", hour, m[2]), fmt.Sprintf("%d:%spm", hour, m[2]), hour, m[2])
+		}
+		return hour, minute, nil
+	}
+	if reBareHour.MatchString(c) {
+		if h, _ := strconv.Atoi(c); h > 12 && h < 24 {
+			return 0, 0, dueError("%q is not a time; use %d:00", c, h)
+		}
+		return 0, 0, dueError("%q is ambiguous; use %sam or %spm", c, c, c)
+	}
+	return 0, 0, dueError("%q is not a time; use e.g. 9am, 5:30pm or 17:30", c)
+}
+
+// parseDueDay resolves the day part of a phrase, with the hour to use when no
+// time is given
+func parseDueDay(day string, now time.Time) (time.Time, int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// days since Monday
+	sinceMonday := (int(today.Weekday()) + 6) % 7
+	switch day {
+	case "", "today":
+		return today, DefaultDueHour, nil
+	case "tonight":
+		return today, TonightHour, nil
+	case "tomorrow", "tmrw", "tmr":
+		return today.AddDate(0, 0, 1), DefaultDueHour, nil
+	case "day after tomorrow", "the day after tomorrow":
+		return today.AddDate(0, 0, 2), DefaultDueHour, nil
+	case "next week":
+		return today.AddDate(0, 0, 7-sinceMonday), DefaultDueHour, nil
+	case "end of day", "eod", "end of the day":
+		return today, EndOfDayHour, nil
+	case "end of week", "end of the week", "eow":
+		// this week's Friday, or next week's at the weekend
+		ahead := 4 - sinceMonday
+		if ahead < 0 {
+			ahead += 7
+		}
+		return today.AddDate(0, 0, ahead), EndOfDayHour, nil
+	case "end of next week":
+		return today.AddDate(0, 0, 11-sinceMonday), EndOfDayHour, nil
+	case "end of month", "end of the month", "eom":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, now.Location()), EndOfDayHour, nil
+	case "end of next month":
+		return time.Date(today.Year(), today.Month()+2, 0, 0, 0, 0, 0, now.Location()), EndOfDayHour, nil
+	}
+
+	words := strings.Fields(day)
+	if len(words) == 1 && reISODate.MatchString(day) {
+		d, err := time.ParseInLocation("2006-01-02", day, now.Location())
+		if err != nil {
+			return time.Time{}, 0, dueError("%q is not a valid date", day)
+		}
+		return d, DefaultDueHour, nil
+	}
+	if len(words) == 1 && reSlashed.MatchString(day) {
+		return time.Time{}, 0, dueError("%q could be day/month or month/day; use YYYY-MM-DD", day)
+	}
+
+	qualifier, name := "", words[len(words)-1]
+	if len(words) == 2 {
+		qualifier = words[0]
+	}
+	if wd, ok := weekdays[name]; ok && len(words) <= 2 {
+		ahead := (int(wd) - int(today.Weekday()) + 7) % 7
+		switch qualifier {
+		case "", "next", "on":
+			if ahead == 0 {
+				ahead = 7
+			}
+		case "this":
+		case "last":
+			return time.Time{}, 0, dueError("%q is in the past", day)
+		default:
+			return time.Time{}, 0, dueError("%q is not understood", day)
+		}
+		return today.AddDate(0, 0, ahead), DefaultDueHour, nil
+	}
+	return time.Time{}, 0, dueError("%q is not understood; try \"tomorrow 9am\", \"next friday\", \"in 2 hours\", \"end of month\" or YYYY-MM-DD", day)
+}
@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+// dueNow is a Wednesday morning
+var dueNow = time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+
+func TestParseDue(t *testing.T) {
+	saturday := dueNow.AddDate(0, 0, 3)
+	sunday := dueNow.AddDate(0, 0, 4)
+	tests := []struct {
+		phrase string
+		now    time.Time
+		want   string // "2006-01-02 15:04" in UTC
+	}{
+		{"now", dueNow, "2026-10-14 10:30"},
+		{"in 30 minutes", dueNow, "2026-10-14 11:00"},
+		{"in an hour", dueNow, "2026-10-14 11:30"},
+		{"in half an hour", dueNow, "2026-10-14 11:00"},
+		{"in 2 hrs", dueNow, "2026-10-14 12:30"},
+		{"in 3 days", dueNow, "2026-10-17 10:30"},
+		{"in 2 weeks", dueNow, "2026-10-28 10:30"},
+		{"in 1 month", dueNow, "2026-11-14 10:30"},
+
+		{"tomorrow", dueNow, "2026-10-15 09:00"},
+		{"Tomorrow.", dueNow, "2026-10-15 09:00"},
+		{"tomorrow 9am!", dueNow, "2026-10-15 09:00"},
+		{"tomorrow at 5:30 pm", dueNow, "2026-10-15 17:30"},
+		{"9am friday", dueNow, "2026-10-16 09:00"},
+		{"tomorrow 9 a.m.", dueNow, "2026-10-15 09:00"},
+		{"9 a.m. tomorrow", dueNow, "2026-10-15 09:00"},
+		{"9 p.m.", dueNow, "2026-10-14 21:00"},
+		{"9:15 P.M.", dueNow, "2026-10-14 21:15"},
+		{"12am tomorrow", dueNow, "2026-10-15 00:00"},
+		{"12pm", dueNow, "2026-10-14 12:00"},
+		{"noon", dueNow, "2026-10-14 12:00"},
+		{"21:30", dueNow, "2026-10-14 21:30"},
+		{"tomorrow 09:30", dueNow, "2026-10-15 09:30"},
+		{"tonight", dueNow, "2026-10-14 20:00"},
+		{"day after tomorrow", dueNow, "2026-10-16 09:00"},
+
+		{"friday", dueNow, "2026-10-16 09:00"},
+		{"next friday", dueNow, "2026-10-16 09:00"},
+		{"wednesday", dueNow, "2026-10-21 09:00"},
+		{"this wednesday 5pm", dueNow, "2026-10-14 17:00"},
+		{"next week", dueNow, "2026-10-19 09:00"},
+		{"2026-11-03", dueNow, "2026-11-03 09:00"},
+		{"2026-11-03 14:00", dueNow, "2026-11-03 14:00"},
+
+		{"end of day", dueNow, "2026-10-14 17:00"},
+		{"end of week", dueNow, "2026-10-16 17:00"},
+		{"eow", dueNow.AddDate(0, 0, 2), "2026-10-16 17:00"},
+		{"end of week", saturday, "2026-10-23 17:00"},
+		{"end of the week", sunday, "2026-10-23 17:00"},
+		{"end of week 9am", dueNow, "2026-10-16 09:00"},
+		{"end of next week", dueNow, "2026-10-23 17:00"},
+		{"end of month", dueNow, "2026-10-31 17:00"},
+		{"end of next month", dueNow, "2026-11-30 17:00"},
+	}
+	for _, tt := range tests {
+		got, err := service.ParseDue(tt.phrase, tt.now, time.UTC)
+		if err != nil {
+			t.Errorf("ParseDue(%q) at %s: %v", tt.phrase, tt.now.Format("Mon 15:04"), err)
+			continue
+		}
+		if s := got.Format("2006-01-02 15:04"); s != tt.want {
+			t.Errorf("ParseDue(%q) at %s = %s, want %s", tt.phrase, tt.now.Format("Mon 15:04"), s, tt.want)
+		}
+	}
+}
+
+func TestParseDueInLocation(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	// 23:00 UTC on Wednesday is already Thursday in India
+	now := time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC)
+	got, err := service.ParseDue("tomorrow 9am", now, ist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 16, 9, 0, 0, 0, ist); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseDueRejects(t *testing.T) {
+	for _, phrase := range []string{
+		"",
+		" . ",
+		"9",          // morning or evening
+		"9:30",       // likewise
+		"tomorrow 9", // likewise
+		"03/04",      // day/month or month/day
+		"13pm",
+		"9:75am",
+		"today 8am",         // passed
+		"this wednesday",    // 09:00 today, passed
+		"tonight 9am",       // a morning time
+		"last friday",       // past
+		"in 0 days",         // not the future
+		"in half an minute", // not a unit that halves
+		"in a fortnight",
+		"someday",
+		"2026-02-30",
+	} {
+		if got, err := service.ParseDue(phrase, dueNow, time.UTC); !errors.Is(err, service.ErrInvalidDue) {
+			t.Errorf("ParseDue(%q) = %v, %v; want ErrInvalidDue", phrase, got, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxEventBacklog caps how many missed events are replayed on resume
+const maxEventBacklog = 1000
+
+// eventGraceIDs is how far below the highest fanned-out ID the broker keeps
+// looking for entries that committed late: concurrent transactions can
+// commit a lower audit ID after a higher one was already streamed
+const eventGraceIDs = 200
+
+// EventSubscription receives live events. C is closed when the subscriber
+// falls too far behind; it should reconnect with the last ID it saw.
+type EventSubscription struct {
+	C      chan models.AuditLog
+	prefix []string
+}
+
+func (sub *EventSubscription) matches(e *models.AuditLog) bool {
+	if len(sub.prefix) == 0 {
+		return true
+	}
+	for _, p := range sub.prefix {
+		if strings.HasPrefix(e.EventType, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// EventBroker fans committed audit entries out to live subscribers. The audit
+// table is the event log: event IDs are audit IDs, so a client can resume
+// from the last ID it saw. Every pass re-reads the last eventGraceIDs IDs, so
+// an entry that commits after a higher ID was fanned out is still streamed,
+// once, out of ID order.
+type EventBroker struct {
+	repo *repository.GormRepo
+	wake chan struct{}
+
+	mu   sync.Mutex
+	subs map[*EventSubscription]struct{}
+	last uint              // highest audit ID fanned out
+	sent map[uint]struct{} // IDs fanned out within the grace window
+}
+
+func NewEventBroker(repo *repository.GormRepo) *EventBroker {
+	return &EventBroker{
+		repo: repo,
+		wake: make(chan struct{}, 1),
+		subs: map[*EventSubscription]struct{}{},
+		sent: map[uint]struct{}{},
+	}
+}
+
+// Wake tells the broker new audit entries were committed
+func (b *EventBroker) Wake() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe registers a subscriber for event types starting with any of
+// prefixes (all when empty) and returns the events after lastID it missed.
+// At most maxEventBacklog entries are read; when there are more, truncatedAt
+// is the last ID read and the caller should end the stream after the backlog
+// so the client resumes from there. A zero lastID starts with live events
+// only. Live events may repeat entries of the backlog; callers skip IDs they
+// sent.
+func (b *EventBroker) Subscribe(lastID uint, prefixes []string) (sub *EventSubscription, backlog []models.AuditLog, truncatedAt uint, err error) {
+	sub = &EventSubscription{C: make(chan models.AuditLog, 256), prefix: prefixes}
+
+	// register first so nothing committed meanwhile is lost between the
+	// backlog query and the live stream
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	if lastID == 0 {
+		return sub, nil, 0, nil
+	}
+	missed, err := b.repo.AuditSince(lastID, maxEventBacklog)
+	if err != nil {
+		b.Unsubscribe(sub)
+		return nil, nil, 0, err
+	}
+	for i := range missed {
+		if sub.matches(&missed[i]) {
+			backlog = append(backlog, missed[i])
+		}
+	}
+	if len(missed) == maxEventBacklog {
+		truncatedAt = missed[len(missed)-1].ID
+	}
+	return sub, backlog, truncatedAt, nil
+}
+
+func (b *EventBroker) Unsubscribe(sub *EventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.C)
+	}
+}
+
+// graceFloor is the ID after which fanOut looks for entries
+func (b *EventBroker) graceFloor() uint {
+	return b.last - min(b.last, eventGraceIDs)
+}
+
+// fanOut reads audit entries committed since the last pass, including late
+// ones within the grace window, and sends each one not sent before to every
+// interested subscriber
+func (b *EventBroker) fanOut() {
+	b.mu.Lock()
+	after := b.graceFloor()
+	b.mu.Unlock()
+
+	const page = 500
+	for {
+		logs, err := b.repo.AuditSince(after, page)
+		if err != nil {
+			log.Errorf("[events] read audit: %v", err)
+			return
+		}
+
+		b.mu.Lock()
+		for i := range logs {
+			e := &logs[i]
+			if _, ok := b.sent[e.ID]; ok {
+				continue
+			}
+			b.sent[e.ID] = struct{}{}
+			b.last = max(b.last, e.ID)
+			for sub := range b.subs {
+				if !sub.matches(e) {
+					continue
+				}
+				select {
+				case sub.C <- *e:
+				default:
+					// too slow; the client resumes from its last ID
+					delete(b.subs, sub)
+					close(sub.C)
+				}
+			}
+		}
+		floor := b.graceFloor()
+		for id := range b.sent {
+			if id <= floor {
+				delete(b.sent, id)
+			}
+		}
+		b.mu.Unlock()
+
+		if len(logs) < page {
+			return
+		}
+		after = logs[len(logs)-1].ID
+	}
+}
+
+// Run fans out new events when woken and at least every poll interval (to
+// catch writes from other processes) until ctx is cancelled
+func (b *EventBroker) Run(ctx context.Context, poll time.Duration) {
+	if err := b.start(); err != nil {
+		log.Errorf("[events] read audit: %v", err)
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.wake:
+		case <-ticker.C:
+		}
+		b.fanOut()
+	}
+}
+
+// start marks the entries written before the broker ran as sent
+func (b *EventBroker) start() error {
+	last, err := b.repo.LastAuditID()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.last = last
+	floor := b.graceFloor()
+	b.mu.Unlock()
+
+	logs, err := b.repo.AuditSince(floor, eventGraceIDs)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range logs {
+		if e.ID <= last {
+			b.sent[e.ID] = struct{}{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+func writeAudit(t *testing.T, repo *repository.GormRepo, id uint, eventType string) {
+	t.Helper()
+	if err := repo.DB.Create(&models.AuditLog{ID: id, EventType: eventType}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// received drains the events waiting on sub
+func received(sub *EventSubscription) []uint {
+	var ids []uint
+	for {
+		select {
+		case e := <-sub.C:
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestEventBrokerStreamsLateEntries(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	b := NewEventBroker(repo)
+	writeAudit(t, repo, 1, "task.create")
+	writeAudit(t, repo, 2, "task.create")
+	if err := b.start(); err != nil {
+		t.Fatal(err)
+	}
+	all, _, _, err := b.Subscribe(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminders, _, _, err := b.Subscribe(0, []string{"reminder."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.fanOut()
+	if got := received(all); len(got) != 0 {
+		t.Errorf("entries from before the broker started were streamed: %v", got)
+	}
+
+	writeAudit(t, repo, 5, "reminder.trigger")
+	b.fanOut()
+	// 3 and 4 commit after 5 was streamed
+	writeAudit(t, repo, 4, "task.update")
+	writeAudit(t, repo, 3, "reminder.trigger")
+	b.fanOut()
+	b.fanOut()
+
+	if got := received(all); len(got) != 3 || got[0] != 5 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("all events = %v, want [5 3 4]", got)
+	}
+	if got := received(reminders); len(got) != 2 || got[0] != 5 || got[1] != 3 {
+		t.Errorf("reminder events = %v, want [5 3]", got)
+	}
+}
+
+func TestEventBrokerTruncatesBacklog(t *testing.T) {
+	repo := repository.NewGormRepo(repotest.Open(t))
+	b := NewEventBroker(repo)
+	for id := uint(1); id <= maxEventBacklog+10; id++ {
+		writeAudit(t, repo, id, "task.create")
+	}
+
+	_, backlog, truncatedAt, err := b.Subscribe(5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog) != maxEventBacklog || backlog[0].ID != 6 || truncatedAt != maxEventBacklog+5 {
+		t.Errorf("backlog of %d from %d, truncated at %d; want %d from 6, truncated at %d",
+			len(backlog), backlog[0].ID, truncatedAt, maxEventBacklog, maxEventBacklog+5)
+	}
+
+	// a filtered backlog still reports where reading stopped
+	_, backlog, truncatedAt, err = b.Subscribe(5, []string{"reminder."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog) != 0 || truncatedAt != maxEventBacklog+5 {
+		t.Errorf("filtered backlog has %d entries, truncated at %d; want 0, %d", len(backlog), truncatedAt, maxEventBacklog+5)
+	}
+
+	_, backlog, truncatedAt, err = b.Subscribe(maxEventBacklog+5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlog) != 5 || truncatedAt != 0 {
+		t.Errorf("resumed backlog has %d entries, truncated at %d; want 5, 0", len(backlog), truncatedAt)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTemplateKey is the key in ReminderRule.Templates used for channels
+// without a template of their own
+const DefaultTemplateKey = "default"
+
+// builtinLinks ends every built-in message with the action links, if any
+const builtinLinks = `{{with .Links}} [done: {{.Done}} | snooze 1h: {{.Snooze}}]{{end}}`
+
+// built-in messages per rule type, used when a rule has no template
+var builtinTemplates = map[string]string{
+	"interval":   `IntervalReminder(rule:{{.Rule.Name}}) -> Task:{{.Task.ID}} {{.Task.Title}} (past due: {{date .Task.DueAt}})` + builtinLinks,
+	"at_due":     `AtDueReminder(rule:{{.Rule.Name}}) -> Task:{{.Task.ID}} {{.Task.Title}} due:{{date .Task.DueAt}}` + builtinLinks,
+	"before_due": `Reminder(rule:{{.Rule.Name}}) -> Task:{{.Task.ID}} {{.Task.Title}} due:{{date .Task.DueAt}}` + builtinLinks,
+}
+
+// MessageData is what a template can use
+type MessageData struct {
+	Rule    *models.ReminderRule
+	Task    *models.Task
+	Channel string
+	Now     time.Time      // when the reminder fired
+	DueIn   string         // e.g. "due in 5 minutes", "due now", "overdue by 2 hours"
+	Links   *ReminderLinks // one-click done/snooze URLs; nil when links are off
+}
+
+var templateFuncs = template.FuncMap{
+	// date formats a time, optionally with a Go layout: {{date .Task.DueAt "15:04"}}
+	"date": func(t time.Time, layout ...string) string {
+		if len(layout) > 0 {
+			return t.Format(layout[0])
+		}
+		return t.Format("02 Jan 2006 15:04")
+	},
+	"humanize": humanizeDuration,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// HumanizeDue describes due relative to now: "due in 5 minutes", "due now"
+// (within a minute) or "overdue by 2 hours"
+func HumanizeDue(due, now time.Time) string {
+	d := due.Sub(now)
+	switch {
+	case d > -time.Minute && d < time.Minute:
+		return "due now"
+	case d > 0:
+		return "due in " + humanizeDuration(d)
+	default:
+		return "overdue by " + humanizeDuration(-d)
+	}
+}
+
+// humanizeDuration rounds d down to its largest unit: "1 minute", "3 hours", "2 days"
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return plural(max(int(d/time.Minute), 1), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// ruleTemplates decodes rr.Templates, a JSON object of channel -> template
+func ruleTemplates(rr *models.ReminderRule) (map[string]string, error) {
+	if strings.TrimSpace(rr.Templates) == "" {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(rr.Templates), &m); err != nil {
+		return nil, fmt.Errorf("%w: templates must be a JSON object of channel to template: %v", ErrInvalidRule, err)
+	}
+	return m, nil
+}
+
+// templateFor picks the rule's template for channel, its default template,
+// or the built-in message for the rule type
+func templateFor(rr *models.ReminderRule, channel string) (string, error) {
+	m, err := ruleTemplates(rr)
+	if err != nil {
+		return "", err
+	}
+	if tpl, ok := m[channel]; ok {
+		return tpl, nil
+	}
+	if tpl, ok := m[DefaultTemplateKey]; ok {
+		return tpl, nil
+	}
+	if tpl, ok := builtinTemplates[rr.RuleType]; ok {
+		return tpl, nil
+	}
+	return builtinTemplates["before_due"], nil
+}
+
+func execTemplate(name, text string, data MessageData) (string, error) {
+	tpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderMessage renders the reminder text of rr for t on a channel, with
+// placeholder action links. Templates are rendered with text/template: no
+// channel delivers HTML yet.
+func RenderMessage(rr *models.ReminderRule, t *models.Task, channel string, now time.Time) (string, error) {
+	return renderMessage(rr, t, channel, now, sampleLinks)
+}
+
+func renderMessage(rr *models.ReminderRule, t *models.Task, channel string, now time.Time, links *ReminderLinks) (string, error) {
+	text, err := templateFor(rr, channel)
+	if err != nil {
+		return "", err
+	}
+	msg, err := execTemplate(channel, text, MessageData{
+		Rule:    rr,
+		Task:    t,
+		Channel: channel,
+		Now:     now,
+		DueIn:   HumanizeDue(t.DueAt, now),
+		Links:   links,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: template for %s: %v", ErrInvalidRule, channel, err)
+	}
+	return msg, nil
+}
+
+// renderOrBuiltin renders the message, falling back to the built-in text so a
+// broken template never stops a reminder from going out
+func renderOrBuiltin(rr *models.ReminderRule, t *models.Task, channel string, now time.Time, links *ReminderLinks) string {
+	msg, err := renderMessage(rr, t, channel, now, links)
+	if err == nil {
+		return msg
+	}
+	log.Warnf("[reminders] rule %d: %v; using the built-in message", rr.ID, err)
+	fallback := *rr
+	fallback.Templates = ""
+	msg, _ = renderMessage(&fallback, t, channel, now, links)
+	return msg
+}
+
+// ValidateTemplates checks that every template of rr parses and renders
+// against a sample task
+func ValidateTemplates(rr *models.ReminderRule) error {
+	m, err := ruleTemplates(rr)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for channel := range m {
+		if _, err := RenderMessage(rr, SampleTask(now), channel, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SampleTask is the task templates are validated and previewed against when
+// no real task is given: due five minutes from now
+func SampleTask(now time.Time) *models.Task {
+	return &models.Task{ID: 1, Title: "Sample task", Status: models.StatusTodo, DueAt: now.Add(5 * time.Minute), Version: 1}
+}
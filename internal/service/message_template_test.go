@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+)
+
+func TestTemplateFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		ruleType  string
+		templates string
+		channel   string
+		want      string
+	}{
+		{name: "channel template", ruleType: "at_due", templates: `{"email": "mail", "default": "any"}`, channel: "email", want: "mail"},
+		{name: "default template", ruleType: "at_due", templates: `{"email": "mail", "default": "any"}`, channel: "log", want: "any"},
+		{name: "built-in for the rule type", ruleType: "interval", templates: `{"email": "mail"}`, channel: "log", want: builtinTemplates["interval"]},
+		{name: "no templates", ruleType: "at_due", channel: "log", want: builtinTemplates["at_due"]},
+		{name: "blank templates", ruleType: "at_due", templates: "  ", channel: "log", want: builtinTemplates["at_due"]},
+		{name: "unknown rule type", ruleType: "weekly", channel: "log", want: builtinTemplates["before_due"]},
+	}
+	for _, tt := range tests {
+		got, err := templateFor(&models.ReminderRule{RuleType: tt.ruleType, Templates: tt.templates}, tt.channel)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: templateFor = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := templateFor(&models.ReminderRule{Templates: `["not", "an", "object"]`}, "log"); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("templateFor with a JSON array = %v, want ErrInvalidRule", err)
+	}
+}
+
+func TestRenderOrBuiltin(t *testing.T) {
+	task := &models.Task{ID: 3, Title: "Pay rent", DueAt: evalAt(2 * time.Hour)}
+	links := &ReminderLinks{Done: "https://x/d", Snooze: "https://x/s"}
+	builtin := "AtDueReminder(rule:r) -> Task:3 Pay rent due:07 Jan 2030 14:00 [done: https://x/d | snooze 1h: https://x/s]"
+	tests := []struct {
+		name      string
+		templates string
+		want      string
+	}{
+		{name: "template", templates: `{"default": "{{upper .Task.Title}} {{.DueIn}} on {{.Channel}}"}`, want: "PAY RENT due in 2 hours on log"},
+		{name: "date layout and links", templates: `{"default": "{{date .Task.DueAt \"15:04\"}} {{.Links.Done}}"}`, want: "14:00 https://x/d"},
+		{name: "built-in", want: builtin},
+		{name: "unknown field falls back", templates: `{"default": "{{.Task.Nope}}"}`, want: builtin},
+		{name: "parse error falls back", templates: `{"default": "{{.Task.Title"}`, want: builtin},
+		{name: "bad JSON falls back", templates: `{"default":`, want: builtin},
+	}
+	for _, tt := range tests {
+		rr := &models.ReminderRule{Name: "r", RuleType: "at_due", Templates: tt.templates}
+		if got := renderOrBuiltin(rr, task, "log", evalAt(0), links); got != tt.want {
+			t.Errorf("%s: renderOrBuiltin = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	rr := &models.ReminderRule{RuleType: "at_due", Templates: `{"default": "{{.Task.Nope}}"}`}
+	if _, err := renderMessage(rr, task, "log", evalAt(0), links); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("renderMessage with an unknown field = %v, want ErrInvalidRule", err)
+	}
+	rr.Templates = `{"default": "{{.Links.Done}}"}`
+	if _, err := renderMessage(rr, task, "log", evalAt(0), nil); err == nil {
+		t.Error("renderMessage without links rendered {{.Links.Done}}")
+	}
+}
+
+func TestHumanizeDue(t *testing.T) {
+	tests := []struct {
+		due  time.Duration // relative to now
+		want string
+	}{
+		{0, "due now"},
+		{59 * time.Second, "due now"},
+		{-59 * time.Second, "due now"},
+		{time.Minute, "due in 1 minute"},
+		{-time.Minute, "overdue by 1 minute"},
+		{59*time.Minute + 59*time.Second, "due in 59 minutes"},
+		{time.Hour, "due in 1 hour"},
+		{-90 * time.Minute, "overdue by 1 hour"},
+		{47*time.Hour + 59*time.Minute, "due in 47 hours"},
+		{48 * time.Hour, "due in 2 days"},
+		{-10 * 24 * time.Hour, "overdue by 10 days"},
+	}
+	for _, tt := range tests {
+		if got := HumanizeDue(evalAt(tt.due), evalAt(0)); got != tt.want {
+			t.Errorf("HumanizeDue(%v) = %q, want %q", tt.due, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	for _, templates := range []string{
+		``,
+		`{}`,
+		`{"default": "{{.Task.Title}} {{.DueIn}}", "email": "{{.Links.Done}} {{humanize 90000000000}}"}`,
+	} {
+		if err := ValidateTemplates(&models.ReminderRule{RuleType: "at_due", Templates: templates}); err != nil {
+			t.Errorf("ValidateTemplates(%s) = %v, want nil", templates, err)
+		}
+	}
+	for _, templates := range []string{
+		`not json`,
+		`{"default": "{{.Task.Title"}`,
+		`{"email": "{{.Task.Nope}}"}`,
+		`{"log": "{{nosuchfunc .Task.Title}}"}`,
+	} {
+		if err := ValidateTemplates(&models.ReminderRule{RuleType: "at_due", Templates: templates}); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("ValidateTemplates(%s) = %v, want ErrInvalidRule", templates, err)
+		}
+	}
+}
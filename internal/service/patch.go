@@ -0,0 +1,49 @@
+package service
+
+import "encoding/json"
+
+// TaskReadOnlyFields are the task fields a merge patch may not touch
+var TaskReadOnlyFields = []string{"id", "version", "created_at", "updated_at", "completed_at", "cancelled_at", "blocked_by"}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396) to the JSON form of
+// current and decodes the result into a fresh value, so removed fields come
+// back as zero values
+func ApplyMergePatch[T any](current *T, patch map[string]any) (*T, error) {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	merged, err := json.Marshal(mergeJSON(doc, patch))
+	if err != nil {
+		return nil, err
+	}
+	var out T
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// mergeJSON implements the RFC 7396 MergePatch algorithm
+func mergeJSON(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeJSON(t[k], v)
+	}
+	return t
+}
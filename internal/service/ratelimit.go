@@ -0,0 +1,155 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// What happens to a notification that hits a rate limit
+const (
+	OverflowDefer  = "defer"  // send it once the limit allows
+	OverflowDigest = "digest" // hold it for a digest of its recipient and channel
+)
+
+// Limit is a token bucket: PerMinute tokens are added every minute, up to
+// Burst. A zero PerMinute means unlimited.
+type Limit struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// RateLimits caps how many notifications the dispatcher sends
+type RateLimits struct {
+	Global       Limit         `json:"global"`
+	Channel      Limit         `json:"channel"`   // per channel
+	Recipient    Limit         `json:"recipient"` // per recipient on each channel; reminders to an assignee only
+	Overflow     string        `json:"overflow"`  // OverflowDefer or OverflowDigest
+	DigestWindow time.Duration `json:"-"`
+}
+
+// RateLimitStats counts how often the limits kicked in since startup
+type RateLimitStats struct {
+	Limited   map[string]int64 `json:"limited"` // by scope: global, channel, recipient
+	Deferred  int64            `json:"deferred"`
+	Collapsed int64            `json:"collapsed"` // held for a digest
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter holds the token buckets of every scope. Buckets live in memory,
+// so each dispatcher process enforces the limits on its own.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limits  RateLimits
+	buckets map[string]*bucket
+	engaged map[string]bool // scope keys currently blocking
+	stats   RateLimitStats
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	return &rateLimiter{
+		limits:  limits,
+		buckets: map[string]*bucket{},
+		engaged: map[string]bool{},
+		stats:   RateLimitStats{Limited: map[string]int64{}},
+	}
+}
+
+// scope is one bucket a notification has to draw from
+type scope struct {
+	name  string // global, channel, recipient
+	key   string
+	limit Limit
+}
+
+// scopes lists the buckets for a notification. Unassigned reminders have no
+// recipient and only draw from the global and channel buckets.
+func (l *rateLimiter) scopes(channel, recipient string, perRecipient bool) []scope {
+	out := []scope{
+		{"global", "global", l.limits.Global},
+		{"channel", "channel:" + channel, l.limits.Channel},
+	}
+	if perRecipient && recipient != "" {
+		out = append(out, scope{"recipient", "recipient:" + channel + ":" + recipient, l.limits.Recipient})
+	}
+	return out
+}
+
+// refill tops the bucket up for the time passed and returns it
+func (l *rateLimiter) refill(s scope, now time.Time) *bucket {
+	b, ok := l.buckets[s.key]
+	burst := float64(max(s.limit.Burst, 1))
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[s.key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Minutes()*s.limit.PerMinute)
+	b.last = now
+	return b
+}
+
+// take draws one token from every scope, or none if any of them is empty.
+// It returns how long to wait for the scarcest token and the scopes that
+// started blocking with this call.
+func (l *rateLimiter) take(channel, recipient string, perRecipient bool, now time.Time) (wait time.Duration, newlyEngaged []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var blocked []scope
+	var drawn []*bucket
+	for _, s := range l.scopes(channel, recipient, perRecipient) {
+		if s.limit.PerMinute <= 0 {
+			continue
+		}
+		b := l.refill(s, now)
+		if b.tokens < 1 {
+			blocked = append(blocked, s)
+			need := time.Duration((1 - b.tokens) / s.limit.PerMinute * float64(time.Minute))
+			wait = max(wait, need)
+			continue
+		}
+		drawn = append(drawn, b)
+	}
+	if len(blocked) == 0 {
+		for _, b := range drawn {
+			b.tokens--
+		}
+		for _, s := range l.scopes(channel, recipient, perRecipient) {
+			delete(l.engaged, s.key)
+		}
+		return 0, nil
+	}
+	for _, s := range blocked {
+		l.stats.Limited[s.name]++
+		if !l.engaged[s.key] {
+			l.engaged[s.key] = true
+			newlyEngaged = append(newlyEngaged, s.key)
+		}
+	}
+	return max(wait, time.Second), newlyEngaged
+}
+
+func (l *rateLimiter) count(collapsed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if collapsed {
+		l.stats.Collapsed++
+	} else {
+		l.stats.Deferred++
+	}
+}
+
+func (l *rateLimiter) snapshot() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.stats
+	out.Limited = map[string]int64{}
+	for k, v := range l.stats.Limited {
+		out.Limited[k] = v
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterScopes(t *testing.T) {
+	now := evalAt(0)
+	tests := []struct {
+		name   string
+		limits RateLimits
+		sends  []string // "channel/recipient"
+		want   []bool   // allowed
+		scope  string   // the scope that blocked
+	}{
+		{
+			name:   "global",
+			limits: RateLimits{Global: Limit{PerMinute: 2, Burst: 2}},
+			sends:  []string{"log/ann", "log/bob", "mail/cid"},
+			want:   []bool{true, true, false},
+			scope:  "global",
+		},
+		{
+			name:   "channel",
+			limits: RateLimits{Channel: Limit{PerMinute: 2, Burst: 2}},
+			sends:  []string{"log/ann", "log/bob", "mail/ann", "log/cid"},
+			want:   []bool{true, true, true, false},
+			scope:  "channel",
+		},
+		{
+			name:   "recipient",
+			limits: RateLimits{Recipient: Limit{PerMinute: 1, Burst: 1}},
+			sends:  []string{"log/ann", "log/bob", "mail/ann", "log/ann"},
+			want:   []bool{true, true, true, false},
+			scope:  "recipient",
+		},
+		{
+			name:   "unassigned reminders skip the recipient bucket",
+			limits: RateLimits{Recipient: Limit{PerMinute: 1, Burst: 1}},
+			sends:  []string{"log/", "log/", "log/", "log/"},
+			want:   []bool{true, true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(tt.limits)
+			for i, send := range tt.sends {
+				channel, to, _ := strings.Cut(send, "/")
+				wait, _ := l.take(channel, to, true, now)
+				if got := wait == 0; got != tt.want[i] {
+					t.Errorf("send %d to %q on %s: allowed = %v, want %v", i, to, channel, got, tt.want[i])
+				}
+			}
+			limited := l.snapshot().Limited
+			for name, n := range limited {
+				if name != tt.scope || n != 1 {
+					t.Errorf("limited[%s] = %d, want only %s once", name, n, tt.scope)
+				}
+			}
+			if tt.scope != "" && limited[tt.scope] != 1 {
+				t.Errorf("limited = %v, want %s once", limited, tt.scope)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(RateLimits{Recipient: Limit{PerMinute: 2, Burst: 1}})
+	now := evalAt(0)
+	if wait, _ := l.take("log", "ann", true, now); wait != 0 {
+		t.Fatalf("first send waits %v", wait)
+	}
+	wait, engaged := l.take("log", "ann", true, now)
+	if wait != 30*time.Second || len(engaged) != 1 || engaged[0] != "recipient:log:ann" {
+		t.Fatalf("second send = %v, %v; want a 30s wait engaging recipient:log:ann", wait, engaged)
+	}
+	if _, engaged := l.take("log", "ann", true, now); len(engaged) != 0 {
+		t.Errorf("a blocking bucket was reported as newly engaged again: %v", engaged)
+	}
+	if wait, _ := l.take("log", "ann", true, now.Add(30*time.Second)); wait != 0 {
+		t.Errorf("send after refill waits %v", wait)
+	}
+}
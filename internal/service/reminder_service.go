@@ -1,22 +1,48 @@
 package service
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Nehyan9895/reminder-system/internal/models"
 	"github.com/Nehyan9895/reminder-system/internal/repository"
-	log "github.com/sirupsen/logrus"
 )
 
 type ReminderService struct {
-	repo *repository.GormRepo
+	repo       *repository.GormRepo
+	queue      *timerQueue
+	dispatcher *Dispatcher
+	webhooks   *WebhookService
+	channels   []string // extra channels for assigned tasks
+	links      *ActionLinks
 }
 
 func NewReminderService(r *repository.GormRepo) *ReminderService {
-	return &ReminderService{repo: r}
+	return &ReminderService{repo: r, queue: newTimerQueue()}
+}
+
+// UseDispatcher wakes d whenever a reminder is queued for delivery
+func (s *ReminderService) UseDispatcher(d *Dispatcher) {
+	s.dispatcher = d
+}
+
+// UseWebhooks publishes reminder.triggered to w as part of recording each reminder
+func (s *ReminderService) UseWebhooks(w *WebhookService) {
+	s.webhooks = w
+}
+
+// UseActionLinks puts signed done/snooze links into every reminder
+func (s *ReminderService) UseActionLinks(a *ActionLinks) {
+	s.links = a
+}
+
+// AddChannel also sends reminders for assigned tasks on channel, besides
+// DefaultChannel
+func (s *ReminderService) AddChannel(channel string) {
+	s.channels = append(s.channels, channel)
 }
 
 type BeforeDueParams struct {
@@ -26,163 +52,255 @@ type IntervalParams struct {
 	IntervalMin int `json:"interval_min"`
 }
 
-// RunOnce: applies rules one pass (idempotent via executions)
-func (s *ReminderService) RunOnce() {
-	log.Info("[scheduler] run pass")
-	rules, err := s.repo.ActiveRules()
-	if err != nil {
-		log.Errorf("fetch rules: %v", err)
-		return
-	}
+// trigger records the execution, its audit entry and the outbox notifications
+// (reminder and webhooks) in one transaction; the dispatcher delivers them afterwards
+func (s *ReminderService) trigger(rr *models.ReminderRule, t *models.Task, now time.Time) error {
+	links := s.links.ForReminder(t, now)
+	msg := renderOrBuiltin(rr, t, DefaultChannel, now, links)
 
-	for _, rr := range rules {
-		switch rr.RuleType {
-		case "before_due":
-			s.applyBeforeDue(&rr)
-		case "interval":
-			s.applyInterval(&rr)
-		case "at_due":
-			s.applyAtDue(&rr)
-		default:
-			log.Warnf("unknown rule type: %s", rr.RuleType)
+	details := fmt.Sprintf(
+		"Reminder triggered [Rule #%d: %s] -> [Task #%d: %s]",
+		rr.ID, rr.Name, t.ID, t.Title,
+	)
+	err := s.repo.Transaction(func(repo *repository.GormRepo) error {
+		if err := repo.CreateExecution(rr.ID, t.ID, now); err != nil {
+			return err
+		}
+		if err := repo.WriteTaskAudit(t.ID, "reminder.trigger", details); err != nil {
+			return err
+		}
+		if err := queueReminder(repo, &models.Notification{
+			RuleID:    rr.ID,
+			TaskID:    t.ID,
+			Channel:   DefaultChannel,
+			Recipient: t.Assignee,
+			Message:   msg,
+		}, now); err != nil {
+			return err
 		}
-		now := time.Now()
-		rr.LastRunAt = &now
-		_ = s.repo.UpdateRule(&rr)
+		for _, ch := range s.channels {
+			if t.Assignee == "" {
+				break // nobody to send to
+			}
+			if err := queueReminder(repo, &models.Notification{
+				RuleID:    rr.ID,
+				TaskID:    t.ID,
+				Channel:   ch,
+				Recipient: t.Assignee,
+				Message:   renderOrBuiltin(rr, t, ch, now, links),
+			}, now); err != nil {
+				return err
+			}
+		}
+		if s.webhooks == nil {
+			return nil
+		}
+		return s.webhooks.publish(repo, EventReminderTriggered, ReminderEventData{
+			Rule:        rr,
+			Task:        t,
+			TriggeredAt: now,
+			Message:     renderOrBuiltin(rr, t, WebhookChannel, now, links),
+			Actions:     links,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if s.dispatcher != nil {
+		s.dispatcher.Wake()
 	}
+	return nil
 }
 
-func (s *ReminderService) applyBeforeDue(rr *models.ReminderRule) {
-	var p BeforeDueParams
-	if err := json.Unmarshal([]byte(rr.Params), &p); err != nil {
-		log.Errorf("invalid params for rule %d: %v", rr.ID, err)
-		return
-	}
+// Preview limits
+const (
+	MaxPreviewHorizon = 30 * 24 * time.Hour
+	maxPreviewFirings = 1000
+	maxFiringsPerTask = 100
+)
 
-	now := time.Now()
+// PreviewFiring is one reminder a rule would send
+type PreviewFiring struct {
+	TaskID    uint      `json:"task_id"`
+	TaskTitle string    `json:"task_title"`
+	DueAt     time.Time `json:"due_at"`
+	FireAt    time.Time `json:"fire_at"`
+}
 
-	windowStart := now
-	windowEnd := now.Add(time.Duration(p.MinutesBefore) * time.Minute)
+// Preview evaluates a rule that is not yet saved against the current tasks
+// and returns when it would fire during [from, to], using the same
+// evaluation as the scheduler. Nothing is written. The bool reports whether
+// the list was truncated.
+func (s *ReminderService) Preview(rr *models.ReminderRule, from, to time.Time) ([]PreviewFiring, bool, error) {
+	ev, err := newRuleEval(rr)
+	if err != nil {
+		return nil, false, err
+	}
 
-	var tasks []models.Task
-	if err := s.repo.DB.
-		Where("status = ? AND due_at BETWEEN ? AND ?", "pending", windowStart, windowEnd).
-		Find(&tasks).Error; err != nil {
-		log.Errorf("fetch tasks: %v", err)
-		return
+	dueFrom, dueTo := ev.dueRange(from, to)
+	tasks, err := s.repo.CandidateTasks(rr.ID, rr.Statuses(), rr.TagNames(), dueFrom, dueTo)
+	if err != nil {
+		return nil, false, err
 	}
 
+	out := []PreviewFiring{}
+	truncated := false
 	for _, t := range tasks {
-		already, err := s.repo.HasExecutionSince(rr.ID, t.ID, windowStart)
-		if err != nil {
-			log.Errorf("check exec: %v", err)
-			continue
+		// an unsaved rule has no executions yet
+		times := ev.firings(&t.Task, nil, from, to, maxFiringsPerTask)
+		if len(times) == maxFiringsPerTask {
+			truncated = true
 		}
-		if already {
-			continue
+		for _, at := range times {
+			out = append(out, PreviewFiring{TaskID: t.ID, TaskTitle: t.Title, DueAt: t.DueAt, FireAt: at})
 		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].FireAt.Before(out[j].FireAt) })
+	if len(out) > maxPreviewFirings {
+		out = out[:maxPreviewFirings]
+		truncated = true
+	}
+	return out, truncated, nil
+}
 
-		msg := fmt.Sprintf("Reminder(rule:%s) -> Task:%d %s due:%s", rr.Name, t.ID, t.Title, t.DueAt.Format("02 Jan 2006 15:04"))
-		log.Info(msg)
+// UpcomingReminder explains when (or why not) an active rule fires next for a task
+type UpcomingReminder struct {
+	RuleID      uint       `json:"rule_id"`
+	RuleName    string     `json:"rule_name"`
+	RuleType    string     `json:"rule_type"`
+	LastFiredAt *time.Time `json:"last_fired_at"`
+	NextFireAt  *time.Time `json:"next_fire_at"` // nil when the rule will not fire
+	Reason      string     `json:"reason"`
+}
 
-		details := fmt.Sprintf(
-			"Reminder triggered [Rule #%d: %s] -> [Task #%d: %s]",
-			rr.ID, rr.Name, t.ID, t.Title,
-		)
-		_ = s.repo.WriteAudit("reminder.trigger", details)
-		_ = s.repo.CreateExecution(rr.ID, t.ID, now)
+// openDependencies lists the open tasks t waits for, e.g. "task #3, task #7"
+func openDependencies(t *models.Task) string {
+	var out []string
+	for _, dep := range t.BlockedBy {
+		if dep.IsOpen() {
+			out = append(out, fmt.Sprintf("task #%d", dep.ID))
+		}
 	}
+	return strings.Join(out, ", ")
 }
 
-func (s *ReminderService) applyInterval(rr *models.ReminderRule) {
-	var p IntervalParams
-	if err := json.Unmarshal([]byte(rr.Params), &p); err != nil {
-		log.Errorf("invalid params for rule %d: %v", rr.ID, err)
-		return
+// Upcoming computes the next firing of every active rule for the task at now
+func (s *ReminderService) Upcoming(t *models.Task, now time.Time) ([]UpcomingReminder, error) {
+	rules, err := s.repo.ActiveRules()
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	var tasks []models.Task
-	// Get only tasks past due and still pending
-	if err := s.repo.DB.Where("status = ? AND due_at <= ?", "pending", now).Find(&tasks).Error; err != nil {
-		log.Errorf("fetch tasks: %v", err)
-		return
+	lastByRule, err := s.repo.LastExecutionsForTask(t.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, t := range tasks {
-		// Check last execution
-		lastExec, err := s.repo.LastExecutionTime(rr.ID, t.ID)
-		if err != nil {
-			log.Errorf("get last exec: %v", err)
-			continue
+	out := []UpcomingReminder{}
+	for _, rr := range rules {
+		u := UpcomingReminder{RuleID: rr.ID, RuleName: rr.Name, RuleType: rr.RuleType}
+		var last *time.Time
+		if at, ok := lastByRule[rr.ID]; ok {
+			last = &at
 		}
+		u.LastFiredAt = last
 
-		// If never reminded OR enough time has passed
-		if lastExec == nil || now.Sub(*lastExec) >= time.Duration(p.IntervalMin)*time.Minute {
-			msg := fmt.Sprintf("IntervalReminder(rule:%s) -> Task:%d %s (past due: %s)",
-				rr.Name, t.ID, t.Title, t.DueAt.Format("02 Jan 2006 15:04"))
-			log.Info(msg)
-
-			details := fmt.Sprintf(
-				"Reminder triggered [Rule #%d: %s] -> [Task #%d: %s]",
-				rr.ID, rr.Name, t.ID, t.Title,
-			)
-
-			_ = s.repo.WriteAudit("reminder.trigger", details)
-			_ = s.repo.CreateExecution(rr.ID, t.ID, time.Now())
+		ev, err := newRuleEval(&rr)
+		switch {
+		case err != nil:
+			u.Reason = err.Error()
+		case !slices.Contains(rr.Statuses(), t.Status):
+			u.Reason = fmt.Sprintf("rule does not remind tasks in status %s", t.Status)
+		case !rr.AppliesTo(t):
+			u.Reason = fmt.Sprintf("rule only reminds tasks tagged %s", rr.RemindTags)
+		case t.Blocked():
+			u.Reason = "blocked by open " + openDependencies(t)
+		default:
+			at, until, ok := ev.next(t, last)
+			switch {
+			case !ok:
+				u.Reason = "already reminded"
+			case !until.IsZero() && now.After(until):
+				u.Reason = fmt.Sprintf("reminder window closed at %s", until.Format(time.RFC3339))
+			case at.Before(now):
+				u.NextFireAt = &now
+				u.Reason = "due now"
+			default:
+				u.NextFireAt = &at
+				u.Reason = "scheduled"
+			}
 		}
+		out = append(out, u)
 	}
+	return out, nil
 }
 
-func (s *ReminderService) applyAtDue(rr *models.ReminderRule) {
-	// Parse params if needed (could include window tolerance, e.g., 1 min)
-	now := time.Now()
-	var tasks []models.Task
-	if err := s.repo.DB.Where("status = ? AND due_at BETWEEN ? AND ?", "pending", now.Add(-1*time.Minute), now.Add(1*time.Minute)).Find(&tasks).Error; err != nil {
-		log.Errorf("fetch tasks: %v", err)
-		return
-	}
-
-	for _, t := range tasks {
-		lastExec, err := s.repo.LastExecutionTime(rr.ID, t.ID)
-		if err != nil {
-			log.Errorf("get last exec: %v", err)
-
-			continue
-		}
-		if lastExec != nil {
-			continue // already reminded
-		}
-
-		if now.Equal(t.DueAt) || now.After(t.DueAt) {
-			msg := fmt.Sprintf("AtDueReminder(rule:%s) -> Task:%d %s due:%s", rr.Name, t.ID, t.Title, t.DueAt.Format("02 Jan 2006 15:04"))
-			log.Info(msg)
+// statsLookahead bounds how far ahead RuleStats looks for the next firing
+const statsLookahead = 7 * 24 * time.Hour
 
-			details := fmt.Sprintf(
-				"Reminder triggered [Rule #%d: %s] -> [Task #%d: %s]",
-				rr.ID, rr.Name, t.ID, t.Title,
-			)
+// RuleStats describes how effective a rule has been
+type RuleStats struct {
+	RuleID        uint                  `json:"rule_id"`
+	Active        bool                  `json:"active"`
+	TotalFired    int64                 `json:"total_fired"`
+	DistinctTasks int64                 `json:"distinct_tasks"`
+	FiredPerDay   []repository.DayCount `json:"fired_per_day"`
+	LastRunAt     *time.Time            `json:"last_run_at"` // last time one of its queued reminders came due and was evaluated
+	LastFiredAt   *time.Time            `json:"last_fired_at"`
+	NextFireAt    *time.Time            `json:"next_fire_at"` // nil if inactive or nothing due within a week
+	Failures      int64                 `json:"failures"`     // failed delivery attempts
+}
 
-			_ = s.repo.WriteAudit("reminder.trigger", details)
-			_ = s.repo.CreateExecution(rr.ID, t.ID, now)
+// RuleStats aggregates the rule's executions over the last days days
+func (s *ReminderService) RuleStats(rr *models.ReminderRule, days int, now time.Time) (*RuleStats, error) {
+	sum, err := s.repo.SummarizeRuleExecutions(rr.ID)
+	if err != nil {
+		return nil, err
+	}
+	since := now.AddDate(0, 0, -days+1).Truncate(24 * time.Hour)
+	perDay, err := s.repo.RuleExecutionsPerDay(rr.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := s.repo.CountRuleDeliveryFailures(rr.ID)
+	if err != nil {
+		return nil, err
+	}
+	st := &RuleStats{
+		RuleID:        rr.ID,
+		Active:        rr.Active,
+		TotalFired:    sum.Total,
+		DistinctTasks: sum.DistinctTasks,
+		FiredPerDay:   perDay,
+		LastRunAt:     rr.LastRunAt,
+		LastFiredAt:   sum.LastFiredAt,
+		Failures:      failures,
+	}
+	if rr.Active {
+		if st.NextFireAt, err = s.nextFiring(rr, now, now.Add(statsLookahead)); err != nil {
+			return nil, err
 		}
-
 	}
+	return st, nil
 }
 
-// StartScheduler runs periodic loop in a goroutine and returns a cancel function via context
-func (s *ReminderService) StartScheduler(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	// run once immediately
-	s.RunOnce()
-	for {
-		select {
-		case <-ctx.Done():
-			log.Info("scheduler stopping")
-			return
-		case <-ticker.C:
-			s.RunOnce()
+// nextFiring returns the earliest time in [from, to] at which the rule fires
+// for any task, taking recorded executions into account
+func (s *ReminderService) nextFiring(rr *models.ReminderRule, from, to time.Time) (*time.Time, error) {
+	ev, err := newRuleEval(rr)
+	if err != nil {
+		return nil, nil // invalid rules never fire
+	}
+	dueFrom, dueTo := ev.dueRange(from, to)
+	tasks, err := s.repo.CandidateTasks(rr.ID, rr.Statuses(), rr.TagNames(), dueFrom, dueTo)
+	if err != nil {
+		return nil, err
+	}
+	var next *time.Time
+	for _, t := range tasks {
+		if times := ev.firings(&t.Task, t.LastTriggeredAt, from, to, 1); len(times) > 0 && (next == nil || times[0].Before(*next)) {
+			next = &times[0]
 		}
 	}
+	return next, nil
 }
@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestRuleStats(t *testing.T) {
+	repo := newTestRepo(t)
+	svc := service.NewReminderService(repo)
+	rr := &models.ReminderRule{Name: "hourly", RuleType: "interval", Params: `{"interval_min": 60}`}
+	if err := repo.CreateRule(rr); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 10, 15, 0, 0, 0, time.UTC)
+	for _, e := range []struct {
+		task uint
+		at   time.Time
+	}{
+		{1, now.AddDate(0, 0, -30)}, // before the window
+		{1, now.AddDate(0, 0, -2)},
+		{2, now.AddDate(0, 0, -2).Add(time.Hour)},
+		{1, now.Add(-time.Hour)},
+	} {
+		if err := repo.CreateExecution(rr.ID, e.task, e.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := svc.RuleStats(rr, 7, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.TotalFired != 4 || st.DistinctTasks != 2 {
+		t.Errorf("fired %d for %d tasks, want 4 for 2", st.TotalFired, st.DistinctTasks)
+	}
+	if st.LastFiredAt == nil || !st.LastFiredAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("last fired at %v, want %v", st.LastFiredAt, now.Add(-time.Hour))
+	}
+	want := []struct {
+		day   time.Time
+		count int64
+	}{
+		{time.Date(2030, 1, 8, 0, 0, 0, 0, time.UTC), 2},
+		{time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC), 1},
+	}
+	if len(st.FiredPerDay) != len(want) {
+		t.Fatalf("fired per day = %+v, want %+v", st.FiredPerDay, want)
+	}
+	for i, w := range want {
+		if got := st.FiredPerDay[i]; !got.Day.Equal(w.day) || got.Count != w.count {
+			t.Errorf("day %d = %s x%d, want %s x%d", i, got.Day, got.Count, w.day, w.count)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+)
+
+// atDueTolerance is how long after the due time an at_due reminder may still fire
+const atDueTolerance = time.Minute
+
+// ErrInvalidRule is returned for rules whose type or params cannot be evaluated
+var ErrInvalidRule = errors.New("invalid rule")
+
+// ruleEval decides when a rule fires for a task. It does no I/O, so the
+// scheduler and the preview endpoint share exactly the same decisions.
+type ruleEval struct {
+	ruleType string
+	created  time.Time     // snoozes that ended before the rule existed are ignored
+	before   time.Duration // before_due
+	every    time.Duration // interval
+}
+
+func newRuleEval(rr *models.ReminderRule) (ruleEval, error) {
+	ev := ruleEval{ruleType: rr.RuleType, created: rr.CreatedAt}
+	switch rr.RuleType {
+	case "before_due":
+		var p BeforeDueParams
+		if err := json.Unmarshal([]byte(rr.Params), &p); err != nil {
+			return ev, fmt.Errorf("%w: invalid params: %v", ErrInvalidRule, err)
+		}
+		if p.MinutesBefore <= 0 {
+			return ev, fmt.Errorf("%w: minutes_before must be positive", ErrInvalidRule)
+		}
+		ev.before = time.Duration(p.MinutesBefore) * time.Minute
+	case "interval":
+		var p IntervalParams
+		if err := json.Unmarshal([]byte(rr.Params), &p); err != nil {
+			return ev, fmt.Errorf("%w: invalid params: %v", ErrInvalidRule, err)
+		}
+		if p.IntervalMin <= 0 {
+			return ev, fmt.Errorf("%w: interval_min must be positive", ErrInvalidRule)
+		}
+		ev.every = time.Duration(p.IntervalMin) * time.Minute
+	case "at_due":
+	default:
+		return ev, fmt.Errorf("%w: unknown rule type: %s", ErrInvalidRule, rr.RuleType)
+	}
+	return ev, nil
+}
+
+// dueRange bounds the due_at of tasks that can fire somewhere in [from, to];
+// a nil bound is open
+func (e ruleEval) dueRange(from, to time.Time) (dueFrom, dueTo *time.Time) {
+	switch e.ruleType {
+	case "before_due":
+		hi := to.Add(e.before)
+		return &from, &hi
+	case "interval":
+		return nil, &to
+	default: // at_due
+		lo := from.Add(-atDueTolerance)
+		return &lo, &to
+	}
+}
+
+// next returns when the rule is next due to fire for t given its last
+// execution, and until when that firing stays valid (zero = no limit).
+// While t is snoozed, a firing due before the snooze ends is moved to its
+// end, and a rule that already reminded about t fires once more then.
+func (e ruleEval) next(t *models.Task, last *time.Time) (at, until time.Time, ok bool) {
+	at, until, ok = e.unsnoozed(t, last)
+	s := t.SnoozedUntil
+	if s == nil || s.Before(e.created) || (last != nil && !last.Before(*s)) {
+		return at, until, ok
+	}
+	if !ok || at.Before(*s) {
+		return *s, time.Time{}, true
+	}
+	return at, until, ok
+}
+
+func (e ruleEval) unsnoozed(t *models.Task, last *time.Time) (at, until time.Time, ok bool) {
+	switch e.ruleType {
+	case "before_due":
+		// one reminder per window [due-before, due]
+		open := t.DueAt.Add(-e.before)
+		if last != nil && !last.Before(open) {
+			return at, until, false
+		}
+		return open, t.DueAt, true
+	case "interval":
+		// repeat every interval once the task is past due
+		if last == nil {
+			return t.DueAt, until, true
+		}
+		return last.Add(e.every), until, true
+	default: // at_due
+		if last != nil {
+			return at, until, false
+		}
+		return t.DueAt, t.DueAt.Add(atDueTolerance), true
+	}
+}
+
+// firesAt reports whether a scheduler pass at now should fire for t
+func (e ruleEval) firesAt(t *models.Task, last *time.Time, now time.Time) bool {
+	at, until, ok := e.next(t, last)
+	return ok && !at.After(now) && (until.IsZero() || !now.After(until))
+}
+
+// firings lists the times at which the rule would fire for t in [from, to],
+// assuming every firing is recorded, stopping after max entries
+func (e ruleEval) firings(t *models.Task, last *time.Time, from, to time.Time, max int) []time.Time {
+	var out []time.Time
+	for len(out) < max {
+		at, until, ok := e.next(t, last)
+		if !ok {
+			break
+		}
+		if at.Before(from) {
+			// overdue: fires on the first pass, if still valid
+			if !until.IsZero() && from.After(until) {
+				break
+			}
+			at = from
+		}
+		if at.After(to) {
+			break
+		}
+		out = append(out, at)
+		fired := at
+		last = &fired
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+)
+
+var evalBase = time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC)
+
+// evalAt returns evalBase shifted by d
+func evalAt(d time.Duration) time.Time { return evalBase.Add(d) }
+
+// evalPtr returns a pointer to evalBase shifted by d
+func evalPtr(d time.Duration) *time.Time {
+	t := evalAt(d)
+	return &t
+}
+
+func mustEval(t *testing.T, ruleType, params string) ruleEval {
+	t.Helper()
+	ev, err := newRuleEval(&models.ReminderRule{RuleType: ruleType, Params: params, CreatedAt: evalAt(-24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestNewRuleEvalRejectsBadRules(t *testing.T) {
+	for _, rr := range []models.ReminderRule{
+		{RuleType: "weekly"},
+		{RuleType: "before_due", Params: `{"minutes_before": 0}`},
+		{RuleType: "before_due", Params: `not json`},
+		{RuleType: "interval", Params: `{"interval_min": -5}`},
+	} {
+		if _, err := newRuleEval(&rr); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s %s: err = %v, want ErrInvalidRule", rr.RuleType, rr.Params, err)
+		}
+	}
+}
+
+func TestRuleEvalNext(t *testing.T) {
+	const minute = time.Minute
+	tests := []struct {
+		name              string
+		ruleType, params  string
+		snoozed, last     *time.Time
+		wantAt, wantUntil time.Time // zero wantAt = will not fire
+	}{
+		{name: "before_due opens the window", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			wantAt: evalAt(-10 * minute), wantUntil: evalAt(0)},
+		{name: "before_due fires once per window", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			last: evalPtr(-5 * minute)},
+		{name: "before_due fired on the window's first second", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			last: evalPtr(-10 * minute)},
+		{name: "before_due fired before the window", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			last: evalPtr(-11 * minute), wantAt: evalAt(-10 * minute), wantUntil: evalAt(0)},
+		{name: "interval starts at due", ruleType: "interval", params: `{"interval_min": 30}`,
+			wantAt: evalAt(0)},
+		{name: "interval repeats after last", ruleType: "interval", params: `{"interval_min": 30}`,
+			last: evalPtr(45 * minute), wantAt: evalAt(75 * minute)},
+		{name: "at_due", ruleType: "at_due",
+			wantAt: evalAt(0), wantUntil: evalAt(atDueTolerance)},
+		{name: "at_due fires once", ruleType: "at_due", last: evalPtr(0)},
+
+		{name: "snooze moves an earlier firing", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			snoozed: evalPtr(-2 * minute), wantAt: evalAt(-2 * minute)},
+		{name: "snooze after the window fires once more", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			snoozed: evalPtr(30 * minute), last: evalPtr(-10 * minute), wantAt: evalAt(30 * minute)},
+		{name: "snooze already served", ruleType: "before_due", params: `{"minutes_before": 10}`,
+			snoozed: evalPtr(-5 * minute), last: evalPtr(-5 * minute)},
+		{name: "snooze ending before the next interval", ruleType: "interval", params: `{"interval_min": 30}`,
+			snoozed: evalPtr(10 * minute), last: evalPtr(0), wantAt: evalAt(30 * minute)},
+		{name: "snooze ending after the next interval", ruleType: "interval", params: `{"interval_min": 30}`,
+			snoozed: evalPtr(40 * minute), last: evalPtr(0), wantAt: evalAt(40 * minute)},
+		{name: "at_due snoozed past its tolerance", ruleType: "at_due",
+			snoozed: evalPtr(20 * minute), wantAt: evalAt(20 * minute)},
+		{name: "at_due reminded again when the snooze ends", ruleType: "at_due",
+			snoozed: evalPtr(20 * minute), last: evalPtr(0), wantAt: evalAt(20 * minute)},
+		{name: "snooze older than the rule is ignored", ruleType: "at_due",
+			snoozed: evalPtr(-48 * time.Hour), last: evalPtr(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := mustEval(t, tt.ruleType, tt.params)
+			task := &models.Task{DueAt: evalBase, SnoozedUntil: tt.snoozed}
+			gotAt, gotUntil, ok := ev.next(task, tt.last)
+			if tt.wantAt.IsZero() {
+				if ok {
+					t.Fatalf("next = %v, want no firing", gotAt)
+				}
+				return
+			}
+			if !ok || !gotAt.Equal(tt.wantAt) || !gotUntil.Equal(tt.wantUntil) {
+				t.Errorf("next = %v until %v (ok %v), want %v until %v", gotAt, gotUntil, ok, tt.wantAt, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestRuleEvalFiresAt(t *testing.T) {
+	const minute = time.Minute
+	tests := []struct {
+		ruleType, params string
+		last             *time.Time
+		now              time.Duration
+		want             bool
+	}{
+		{"before_due", `{"minutes_before": 10}`, nil, -11 * minute, false},
+		{"before_due", `{"minutes_before": 10}`, nil, -10 * minute, true},
+		{"before_due", `{"minutes_before": 10}`, nil, 0, true},
+		{"before_due", `{"minutes_before": 10}`, nil, time.Second, false},
+		{"interval", `{"interval_min": 30}`, nil, -time.Second, false},
+		{"interval", `{"interval_min": 30}`, nil, 0, true},
+		{"interval", `{"interval_min": 30}`, nil, 24 * time.Hour, true},
+		{"interval", `{"interval_min": 30}`, evalPtr(0), 29 * minute, false},
+		{"interval", `{"interval_min": 30}`, evalPtr(0), 30 * minute, true},
+		{"at_due", ``, nil, -time.Second, false},
+		{"at_due", ``, nil, atDueTolerance, true},
+		{"at_due", ``, nil, atDueTolerance + time.Second, false},
+		{"at_due", ``, evalPtr(0), 30 * time.Second, false},
+	}
+	for _, tt := range tests {
+		ev := mustEval(t, tt.ruleType, tt.params)
+		task := &models.Task{DueAt: evalBase}
+		if got := ev.firesAt(task, tt.last, evalAt(tt.now)); got != tt.want {
+			t.Errorf("%s %s last %v: firesAt(due%+v) = %v, want %v", tt.ruleType, tt.params, tt.last, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestRuleEvalDueRange(t *testing.T) {
+	from, to := evalAt(0), evalAt(time.Hour)
+	check := func(name string, got *time.Time, want time.Time) {
+		t.Helper()
+		switch {
+		case want.IsZero() && got != nil:
+			t.Errorf("%s = %v, want open", name, *got)
+		case !want.IsZero() && (got == nil || !got.Equal(want)):
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	lo, hi := mustEval(t, "before_due", `{"minutes_before": 10}`).dueRange(from, to)
+	check("before_due from", lo, from)
+	check("before_due to", hi, to.Add(10*time.Minute))
+
+	lo, hi = mustEval(t, "interval", `{"interval_min": 5}`).dueRange(from, to)
+	check("interval from", lo, time.Time{})
+	check("interval to", hi, to)
+
+	lo, hi = mustEval(t, "at_due", ``).dueRange(from, to)
+	check("at_due from", lo, from.Add(-atDueTolerance))
+	check("at_due to", hi, to)
+}
+
+func TestRuleEvalFirings(t *testing.T) {
+	task := &models.Task{DueAt: evalBase}
+
+	got := mustEval(t, "interval", `{"interval_min": 20}`).firings(task, nil, evalAt(0), evalAt(time.Hour), 100)
+	want := []time.Time{evalAt(0), evalAt(20 * time.Minute), evalAt(40 * time.Minute), evalAt(time.Hour)}
+	if len(got) != len(want) {
+		t.Fatalf("interval firings = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("interval firing %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := mustEval(t, "interval", `{"interval_min": 1}`).firings(task, nil, evalAt(0), evalAt(time.Hour), 5); len(got) != 5 {
+		t.Errorf("firings returned %d entries, want the max of 5", len(got))
+	}
+
+	// an overdue before_due reminder fires at the start of the range while its window is open
+	got = mustEval(t, "before_due", `{"minutes_before": 10}`).firings(task, nil, evalAt(-5*time.Minute), evalAt(time.Hour), 10)
+	if len(got) != 1 || !got[0].Equal(evalAt(-5*time.Minute)) {
+		t.Errorf("overdue before_due firings = %v, want [%v]", got, evalAt(-5*time.Minute))
+	}
+	// and not at all once it has closed
+	if got := mustEval(t, "at_due", ``).firings(task, nil, evalAt(time.Hour), evalAt(2*time.Hour), 10); len(got) != 0 {
+		t.Errorf("at_due firings after its tolerance = %v, want none", got)
+	}
+}
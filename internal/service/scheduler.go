@@ -0,0 +1,378 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// fireKey identifies one (rule, task) pair in the queue
+type fireKey struct {
+	ruleID, taskID uint
+}
+
+// fireEntry is the next time a rule is due to fire for a task
+type fireEntry struct {
+	key   fireKey
+	at    time.Time
+	index int // position in the heap, maintained by fireQueue
+}
+
+// fireQueue is a min-heap of entries ordered by fire time
+type fireQueue []*fireEntry
+
+func (q fireQueue) Len() int           { return len(q) }
+func (q fireQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q fireQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *fireQueue) Push(x any) {
+	e := x.(*fireEntry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+func (q *fireQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	e.index = -1
+	return e
+}
+
+// timerQueue holds the next fire time of every (rule, task) pair that will
+// fire again. Entries are indexed by rule and by task so either can be
+// dropped without scanning the queue. It is safe for concurrent use.
+type timerQueue struct {
+	mu      sync.Mutex
+	heap    fireQueue
+	entries map[fireKey]*fireEntry
+	byRule  map[uint]map[fireKey]struct{}
+	byTask  map[uint]map[fireKey]struct{}
+	wake    chan struct{}
+}
+
+func newTimerQueue() *timerQueue {
+	return &timerQueue{
+		entries: map[fireKey]*fireEntry{},
+		byRule:  map[uint]map[fireKey]struct{}{},
+		byTask:  map[uint]map[fireKey]struct{}{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// set schedules (or reschedules) key at the given time
+func (q *timerQueue) set(key fireKey, at time.Time) {
+	q.mu.Lock()
+	if e, ok := q.entries[key]; ok {
+		e.at = at
+		heap.Fix(&q.heap, e.index)
+	} else {
+		e = &fireEntry{key: key, at: at}
+		heap.Push(&q.heap, e)
+		q.entries[key] = e
+		indexKey(q.byRule, key.ruleID, key)
+		indexKey(q.byTask, key.taskID, key)
+	}
+	earliest := q.heap[0].key == key
+	q.mu.Unlock()
+	if earliest {
+		q.signal()
+	}
+}
+
+func indexKey(m map[uint]map[fireKey]struct{}, id uint, key fireKey) {
+	keys, ok := m[id]
+	if !ok {
+		keys = map[fireKey]struct{}{}
+		m[id] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func unindexKey(m map[uint]map[fireKey]struct{}, id uint, key fireKey) {
+	delete(m[id], key)
+	if len(m[id]) == 0 {
+		delete(m, id)
+	}
+}
+
+// forget drops key from the entry map and the indexes; the caller removes it
+// from the heap. q.mu must be held.
+func (q *timerQueue) forget(key fireKey) {
+	delete(q.entries, key)
+	unindexKey(q.byRule, key.ruleID, key)
+	unindexKey(q.byTask, key.taskID, key)
+}
+
+// drop removes the entry for key, if any. q.mu must be held.
+func (q *timerQueue) drop(key fireKey) {
+	if e, ok := q.entries[key]; ok {
+		heap.Remove(&q.heap, e.index)
+		q.forget(key)
+	}
+}
+
+// removeKey drops the entry for key, if any
+func (q *timerQueue) removeKey(key fireKey) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.drop(key)
+}
+
+// removeRule drops every entry of a rule
+func (q *timerQueue) removeRule(id uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key := range q.byRule[id] {
+		q.drop(key)
+	}
+}
+
+// removeTask drops every entry of a task
+func (q *timerQueue) removeTask(id uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key := range q.byTask[id] {
+		q.drop(key)
+	}
+}
+
+// removeRulesExcept drops the entries of every rule not in keep
+func (q *timerQueue) removeRulesExcept(keep map[uint]bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, keys := range q.byRule {
+		if keep[id] {
+			continue
+		}
+		for key := range keys {
+			q.drop(key)
+		}
+	}
+}
+
+// next returns the earliest fire time, if any
+func (q *timerQueue) next() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.heap) == 0 {
+		return time.Time{}, false
+	}
+	return q.heap[0].at, true
+}
+
+// popDue removes and returns every entry due at or before now
+func (q *timerQueue) popDue(now time.Time) []fireKey {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []fireKey
+	for len(q.heap) > 0 && !q.heap[0].at.After(now) {
+		e := heap.Pop(&q.heap).(*fireEntry)
+		q.forget(e.key)
+		due = append(due, e.key)
+	}
+	return due
+}
+
+func (q *timerQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap)
+}
+
+func (q *timerQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule computes the next firing of rr for t and queues it, or drops the
+// pair when the rule will not fire for the task again. Blocked tasks are
+// dropped until their dependencies are finished.
+func (s *ReminderService) schedule(rr *models.ReminderRule, ev ruleEval, t *models.Task, last *time.Time, now time.Time) {
+	key := fireKey{ruleID: rr.ID, taskID: t.ID}
+	if !rr.Active || !rr.AppliesTo(t) || t.Blocked() {
+		s.queue.removeKey(key)
+		return
+	}
+	at, until, ok := ev.next(t, last)
+	if !ok || (!until.IsZero() && now.After(until)) {
+		s.queue.removeKey(key)
+		return
+	}
+	s.queue.set(key, at)
+}
+
+// Rebuild reloads the whole queue from the database
+func (s *ReminderService) Rebuild() {
+	now := time.Now()
+	rules, err := s.repo.ActiveRules()
+	if err != nil {
+		log.Errorf("[scheduler] fetch rules: %v", err)
+		return
+	}
+	active := map[uint]bool{}
+	for i := range rules {
+		active[rules[i].ID] = true
+		s.scheduleRule(&rules[i], now)
+	}
+	s.queue.removeRulesExcept(active)
+	log.Infof("[scheduler] queue rebuilt: %d pending reminders", s.queue.len())
+}
+
+// scheduleRule (re)queues rr for every task it can still fire for
+func (s *ReminderService) scheduleRule(rr *models.ReminderRule, now time.Time) {
+	s.queue.removeRule(rr.ID)
+	ev, err := newRuleEval(rr)
+	if err != nil {
+		log.Errorf("rule %d: %v", rr.ID, err)
+		return
+	}
+	// only a lower due_at bound applies: the queue covers every future firing
+	dueFrom, _ := ev.dueRange(now, now)
+	// tasks and their last executions come back in one query
+	tasks, err := s.repo.CandidateTasks(rr.ID, rr.Statuses(), rr.TagNames(), dueFrom, nil)
+	if err != nil {
+		log.Errorf("[scheduler] fetch tasks: %v", err)
+		return
+	}
+	for i := range tasks {
+		s.schedule(rr, ev, &tasks[i].Task, tasks[i].LastTriggeredAt, now)
+	}
+}
+
+// RuleChanged requeues a created, edited, activated or deactivated rule
+func (s *ReminderService) RuleChanged(id uint) {
+	rr, err := s.repo.GetRuleByID(id)
+	if err != nil || !rr.Active {
+		s.RuleDeleted(id)
+		return
+	}
+	s.scheduleRule(rr, time.Now())
+}
+
+// RuleDeleted drops a rule from the queue
+func (s *ReminderService) RuleDeleted(id uint) {
+	s.queue.removeRule(id)
+}
+
+// TaskChanged requeues a created or updated task for every active rule
+func (s *ReminderService) TaskChanged(id uint) {
+	t, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		s.TaskDeleted(id)
+		return
+	}
+	rules, err := s.repo.ActiveRules()
+	if err != nil {
+		log.Errorf("[scheduler] fetch rules: %v", err)
+		return
+	}
+	lastByRule, err := s.repo.LastExecutionsForTask(t.ID)
+	if err != nil {
+		log.Errorf("get last exec: %v", err)
+		return
+	}
+	now := time.Now()
+	for i := range rules {
+		ev, err := newRuleEval(&rules[i])
+		if err != nil {
+			continue
+		}
+		var last *time.Time
+		if at, ok := lastByRule[rules[i].ID]; ok {
+			last = &at
+		}
+		s.schedule(&rules[i], ev, t, last, now)
+	}
+}
+
+// TaskDeleted drops a task from the queue
+func (s *ReminderService) TaskDeleted(id uint) {
+	s.queue.removeTask(id)
+}
+
+// fireDue fires every queued reminder whose time has come. The rule and task
+// are re-read so a stale entry never sends a wrong reminder.
+func (s *ReminderService) fireDue(now time.Time) {
+	for _, key := range s.queue.popDue(now) {
+		rr, err := s.repo.GetRuleByID(key.ruleID)
+		if err != nil || !rr.Active {
+			continue
+		}
+		t, err := s.repo.GetTaskByID(key.taskID)
+		if err != nil {
+			continue
+		}
+		ev, err := newRuleEval(rr)
+		if err != nil {
+			continue
+		}
+		last, err := s.repo.LastExecutionTime(rr.ID, t.ID)
+		if err != nil {
+			log.Errorf("get last exec: %v", err)
+			// retry shortly rather than losing the reminder
+			s.queue.set(key, now.Add(time.Second))
+			continue
+		}
+		if rr.AppliesTo(t) && !t.Blocked() && ev.firesAt(t, last, now) {
+			if err := s.trigger(rr, t, now); err != nil {
+				log.Errorf("[scheduler] record reminder rule %d task %d: %v", rr.ID, t.ID, err)
+				// nothing was written; retry shortly
+				s.queue.set(key, now.Add(time.Second))
+				continue
+			}
+			last = &now
+		}
+		_ = s.repo.SetRuleLastRun(rr.ID, now)
+		s.schedule(rr, ev, t, last, now)
+	}
+}
+
+// StartScheduler fires reminders at their exact due time from an in-memory
+// queue built from the database. The queue is kept current through
+// RuleChanged/TaskChanged and fully rebuilt every resync interval to pick up
+// changes made outside this process.
+func (s *ReminderService) StartScheduler(ctx context.Context, resync time.Duration) {
+	s.Rebuild()
+	s.fireDue(time.Now())
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		var timerC <-chan time.Time
+		if at, ok := s.queue.next(); ok {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(at))
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("scheduler stopping")
+			return
+		case <-s.queue.wake:
+			// an earlier entry was queued; recompute the timer
+		case <-timerC:
+			s.fireDue(time.Now())
+		case <-ticker.C:
+			s.Rebuild()
+			s.fireDue(time.Now())
+		}
+	}
+}
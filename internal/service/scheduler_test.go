@@ -0,0 +1,281 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+func TestTimerQueue(t *testing.T) {
+	q := newTimerQueue()
+	k1, k2, k3 := fireKey{1, 1}, fireKey{1, 2}, fireKey{2, 1}
+	q.set(k1, evalAt(3*time.Minute))
+	q.set(k2, evalAt(time.Minute))
+	q.set(k3, evalAt(2*time.Minute))
+	if next, _ := q.next(); !next.Equal(evalAt(time.Minute)) {
+		t.Fatalf("next = %v, want the earliest entry", next)
+	}
+
+	q.set(k2, evalAt(5*time.Minute)) // reschedule
+	q.removeKey(k3)
+	q.removeKey(fireKey{9, 9}) // unknown keys are ignored
+	if next, _ := q.next(); !next.Equal(evalAt(3*time.Minute)) || q.len() != 2 {
+		t.Fatalf("next = %v with %d entries, want k1 of 2 entries", next, q.len())
+	}
+
+	if due := q.popDue(evalAt(4 * time.Minute)); len(due) != 1 || due[0] != k1 {
+		t.Errorf("popDue = %v, want [k1]", due)
+	}
+	if due := q.popDue(evalAt(time.Hour)); len(due) != 1 || due[0] != k2 {
+		t.Errorf("popDue = %v, want [k2]", due)
+	}
+	if _, ok := q.next(); ok {
+		t.Error("queue not empty after popping everything")
+	}
+
+	q.set(k1, evalAt(0))
+	q.set(k2, evalAt(0))
+	q.set(k3, evalAt(0))
+	q.removeRule(1)
+	if due := q.popDue(evalAt(0)); len(due) != 1 || due[0] != k3 {
+		t.Errorf("after removing rule 1, popDue = %v, want [k3]", due)
+	}
+
+	k4 := fireKey{3, 1}
+	for _, k := range []fireKey{k1, k2, k3, k4} {
+		q.set(k, evalAt(0))
+	}
+	q.removeTask(1)
+	if q.len() != 1 {
+		t.Fatalf("%d entries after removing task 1, want k2 only", q.len())
+	}
+	q.set(k3, evalAt(0))
+	q.removeRulesExcept(map[uint]bool{2: true})
+	if due := q.popDue(evalAt(0)); len(due) != 1 || due[0] != k3 {
+		t.Errorf("after keeping only rule 2, popDue = %v, want [k3]", due)
+	}
+	if len(q.entries) != 0 || len(q.byRule) != 0 || len(q.byTask) != 0 {
+		t.Errorf("empty queue keeps %d entries, %d rule and %d task indexes", len(q.entries), len(q.byRule), len(q.byTask))
+	}
+}
+
+// schedulerFixture is a reminder service observing a task service over sqlite
+type schedulerFixture struct {
+	repo      *repository.GormRepo
+	reminders *ReminderService
+	tasks     *TaskService
+}
+
+func newSchedulerFixture(t *testing.T) *schedulerFixture {
+	t.Helper()
+	repo := repository.NewGormRepo(repotest.Open(t))
+	f := &schedulerFixture{repo: repo, reminders: NewReminderService(repo), tasks: NewTaskService(repo)}
+	f.tasks.AddObserver(f.reminders)
+	return f
+}
+
+func (f *schedulerFixture) rule(t *testing.T, ruleType, params string) *models.ReminderRule {
+	t.Helper()
+	rr := &models.ReminderRule{Name: ruleType, Active: true, RuleType: ruleType, Params: params}
+	if err := f.repo.CreateRule(rr); err != nil {
+		t.Fatal(err)
+	}
+	f.reminders.RuleChanged(rr.ID)
+	return rr
+}
+
+func (f *schedulerFixture) task(t *testing.T, due time.Time) *models.Task {
+	t.Helper()
+	task := &models.Task{Title: "task", DueAt: due}
+	if err := f.tasks.Create(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func (f *schedulerFixture) executions(t *testing.T) int64 {
+	t.Helper()
+	var n int64
+	if err := f.repo.DB.Model(&models.ReminderExecution{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func (f *schedulerFixture) queued(key fireKey) (time.Time, bool) {
+	f.reminders.queue.mu.Lock()
+	defer f.reminders.queue.mu.Unlock()
+	if e, ok := f.reminders.queue.entries[key]; ok {
+		return e.at, true
+	}
+	return time.Time{}, false
+}
+
+func TestFireDueFiresAndReschedules(t *testing.T) {
+	f := newSchedulerFixture(t)
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	before := f.rule(t, "before_due", `{"minutes_before": 10}`)
+	every := f.rule(t, "interval", `{"interval_min": 30}`)
+	task := f.task(t, due)
+
+	if at, ok := f.queued(fireKey{before.ID, task.ID}); !ok || !at.Equal(due.Add(-10*time.Minute)) {
+		t.Fatalf("before_due queued at %v (%v), want %v", at, ok, due.Add(-10*time.Minute))
+	}
+	if at, ok := f.queued(fireKey{every.ID, task.ID}); !ok || !at.Equal(due) {
+		t.Fatalf("interval queued at %v (%v), want %v", at, ok, due)
+	}
+
+	// nothing is due yet
+	f.reminders.fireDue(due.Add(-11 * time.Minute))
+	if n := f.executions(t); n != 0 {
+		t.Fatalf("%d executions before anything was due", n)
+	}
+	f.reminders.Rebuild()
+	if rr, _ := f.repo.GetRuleByID(every.ID); rr.LastRunAt != nil {
+		t.Errorf("last_run_at = %v after requeueing only, want nil", rr.LastRunAt)
+	}
+
+	f.reminders.fireDue(due)
+	if n := f.executions(t); n != 2 {
+		t.Fatalf("%d executions at due, want 2", n)
+	}
+	if rr, _ := f.repo.GetRuleByID(every.ID); rr.LastRunAt == nil || !rr.LastRunAt.Equal(due) {
+		t.Errorf("last_run_at = %v after firing, want %v", rr.LastRunAt, due)
+	}
+	if _, ok := f.queued(fireKey{before.ID, task.ID}); ok {
+		t.Error("before_due still queued after firing once")
+	}
+	if at, ok := f.queued(fireKey{every.ID, task.ID}); !ok || !at.Equal(due.Add(30*time.Minute)) {
+		t.Errorf("interval requeued at %v (%v), want %v", at, ok, due.Add(30*time.Minute))
+	}
+}
+
+func TestFireDueRechecksRuleAndTask(t *testing.T) {
+	f := newSchedulerFixture(t)
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	rr := f.rule(t, "interval", `{"interval_min": 30}`)
+	deactivated := f.task(t, due)
+
+	// changes made behind the scheduler's back leave stale entries
+	if err := f.repo.SetRuleActive(rr.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	f.reminders.fireDue(due)
+	if n := f.executions(t); n != 0 {
+		t.Fatalf("inactive rule fired %d times", n)
+	}
+	if _, ok := f.queued(fireKey{rr.ID, deactivated.ID}); ok {
+		t.Error("inactive rule still queued")
+	}
+
+	if err := f.repo.SetRuleActive(rr.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	finished := f.task(t, due)
+	blocked := f.task(t, due)
+	blocker := f.task(t, due.Add(24*time.Hour))
+	f.reminders.RuleChanged(rr.ID)
+	if err := f.repo.DB.Model(&models.Task{}).Where("id = ?", finished.ID).Update("status", models.StatusDone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.AddDependency(blocked.ID, blocker.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	f.reminders.fireDue(due)
+	var fired []uint
+	f.repo.DB.Model(&models.ReminderExecution{}).Pluck("task_id", &fired)
+	if len(fired) != 1 || fired[0] != deactivated.ID {
+		t.Errorf("fired for tasks %v, want only %d", fired, deactivated.ID)
+	}
+}
+
+func TestTaskObserverRequeues(t *testing.T) {
+	f := newSchedulerFixture(t)
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	rr := f.rule(t, "at_due", ``)
+	task := f.task(t, due)
+	key := fireKey{rr.ID, task.ID}
+	if _, ok := f.queued(key); !ok {
+		t.Fatal("new task not queued")
+	}
+
+	task, _, err := f.tasks.Transition(task.ID, models.StatusDone, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.queued(key); ok {
+		t.Error("done task still queued")
+	}
+	task, _, err = f.tasks.Transition(task.ID, models.StatusTodo, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.queued(key); !ok {
+		t.Error("reopened task not queued again")
+	}
+
+	later := due.Add(time.Hour)
+	task.DueAt = later
+	if err := f.tasks.Update(task); err != nil {
+		t.Fatal(err)
+	}
+	if at, _ := f.queued(key); !at.Equal(later) {
+		t.Errorf("moved task queued at %v, want %v", at, later)
+	}
+
+	if err := f.tasks.Delete(task.ID, task.Version); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.queued(key); ok {
+		t.Error("deleted task still queued")
+	}
+
+	other := f.task(t, due)
+	f.reminders.RuleDeleted(rr.ID)
+	if _, ok := f.queued(fireKey{rr.ID, other.ID}); ok {
+		t.Error("deleted rule still queued")
+	}
+}
+
+func TestBatchRequeuesDependents(t *testing.T) {
+	f := newSchedulerFixture(t)
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	rr := f.rule(t, "at_due", ``)
+	blocker := f.task(t, due)
+	other := f.task(t, due)
+	waiting := f.task(t, due)
+	for _, dep := range []uint{blocker.ID, other.ID} {
+		if _, err := f.tasks.AddDependency(waiting.ID, dep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := fireKey{rr.ID, waiting.ID}
+	if _, ok := f.queued(key); ok {
+		t.Fatal("blocked task queued")
+	}
+
+	batch := func(ops ...BatchOp) {
+		t.Helper()
+		if _, err := f.tasks.Batch(ops, "c1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch(BatchOp{Op: BatchComplete, ID: blocker.ID})
+	if _, ok := f.queued(key); ok {
+		t.Fatal("task still waiting on another one queued")
+	}
+	batch(BatchOp{Op: BatchDelete, ID: other.ID})
+	if _, ok := f.queued(key); !ok {
+		t.Fatal("task not queued once its last blocker was deleted")
+	}
+
+	blocker, _ = f.tasks.Get(blocker.ID)
+	batch(BatchOp{Op: BatchUpdate, ID: blocker.ID, Version: blocker.Version, patch: map[string]any{"status": models.StatusTodo}})
+	if _, ok := f.queued(key); ok {
+		t.Error("task still queued after its blocker was reopened")
+	}
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+)
+
+// MaxSearchQueryLength caps search queries (in characters)
+const MaxSearchQueryLength = 200
+
+// likeSnippetRunes is how much text the LIKE fallback shows around a match
+const likeSnippetRunes = 160
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// SearchPage is one page of search results
+type SearchPage struct {
+	Query    string                 `json:"query"`
+	Total    int64                  `json:"total"`
+	Limit    int                    `json:"limit"`
+	Offset   int                    `json:"offset"`
+	FullText bool                   `json:"full_text"` // false when the LIKE fallback was used
+	Capped   bool                   `json:"capped"`    // the LIKE fallback only ranked the first of Total matches
+	Results  []repository.SearchHit `json:"results"`
+}
+
+type SearchService struct {
+	repo *repository.GormRepo
+}
+
+func NewSearchService(repo *repository.GormRepo) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+// Search finds tasks whose title or description, or one of whose comments,
+// matches q. With PostgreSQL q is a web-search query ("rent -car", "\"pay
+// rent\"", "rent or mortgage") ranked by ts_rank; otherwise every word must
+// occur and hits are ranked by how often they do, title first. Matches are
+// wrapped in <mark> in the HTML-escaped title and snippet.
+func (s *SearchService) Search(q string, limit, offset int) (*SearchPage, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidQuery)
+	}
+	if utf8.RuneCountInString(q) > MaxSearchQueryLength {
+		return nil, fmt.Errorf("%w: q is longer than %d characters", ErrInvalidQuery, MaxSearchQueryLength)
+	}
+	page := &SearchPage{Query: q, Limit: limit, Offset: offset, FullText: s.repo.FullTextSearch()}
+
+	var hits []repository.SearchHit
+	var err error
+	if page.FullText {
+		hits, page.Total, err = s.repo.SearchTasks(q, limit, offset)
+	} else {
+		hits, page.Total, page.Capped, err = s.searchLike(q, limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Title = markHTML(hits[i].Title)
+		hits[i].Snippet = markHTML(hits[i].Snippet)
+	}
+	if hits == nil {
+		hits = []repository.SearchHit{}
+	}
+	page.Results = hits
+	return page, nil
+}
+
+// searchLike ranks and highlights the LIKE fallback's hits in memory. When
+// more match than the fallback reads, only those read are ranked and paged;
+// total still counts every match and capped is set.
+func (s *SearchService) searchLike(q string, limit, offset int) (hits []repository.SearchHit, total int64, capped bool, err error) {
+	terms := strings.Fields(strings.ToLower(q))
+	hits, total, err = s.repo.SearchTasksLike(terms)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	capped = total > int64(len(hits))
+	for i := range hits {
+		h := &hits[i]
+		h.Rank = 2*float64(countTerms(h.Title, terms)) + float64(countTerms(h.Snippet, terms))
+		h.Title = markTerms(h.Title, terms)
+		h.Snippet = markTerms(excerpt(h.Snippet, terms), terms)
+	}
+	slices.SortStableFunc(hits, func(a, b repository.SearchHit) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), cmp.Compare(a.TaskID, b.TaskID), cmp.Compare(a.CommentID, b.CommentID))
+	})
+	if offset >= len(hits) {
+		return nil, total, capped, nil
+	}
+	return hits[offset:min(offset+limit, len(hits))], total, capped, nil
+}
+
+// countTerms counts the occurrences of terms in s, ignoring case
+func countTerms(s string, terms []string) int {
+	s = strings.ToLower(s)
+	n := 0
+	for _, t := range terms {
+		n += strings.Count(s, t)
+	}
+	return n
+}
+
+// excerpt cuts s down to about likeSnippetRunes around the first match
+func excerpt(s string, terms []string) string {
+	runes := []rune(s)
+	if len(runes) <= likeSnippetRunes {
+		return s
+	}
+	first := len(runes)
+	if lower := []rune(strings.ToLower(s)); len(lower) == len(runes) {
+		for _, t := range terms {
+			if i := runeIndex(lower, []rune(t)); i >= 0 && i < first {
+				first = i
+			}
+		}
+	}
+	if first == len(runes) {
+		first = 0
+	}
+	start := max(first-likeSnippetRunes/4, 0)
+	end := min(start+likeSnippetRunes, len(runes))
+	out := string(runes[start:end])
+	if start > 0 {
+		out = "..." + out
+	}
+	if end < len(runes) {
+		out += "..."
+	}
+	return out
+}
+
+// runeIndex is strings.Index on runes, returning a rune position
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// markTerms wraps every case-insensitive occurrence of terms in s in
+// highlight markers
+func markTerms(s string, terms []string) string {
+	runes := []rune(s)
+	lower := []rune(strings.ToLower(s))
+	if len(lower) != len(runes) {
+		return s // case folding changed the length; leave unhighlighted
+	}
+	marked := make([]bool, len(runes))
+	for _, t := range terms {
+		tr := []rune(t)
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(tr)], tr) {
+				for j := i; j < i+len(tr); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(repository.HighlightStart)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(repository.HighlightStop)
+		}
+	}
+	return b.String()
+}
+
+// markHTML escapes s and turns highlight markers into <mark> tags
+func markHTML(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, repository.HighlightStart, "<mark>")
+	return strings.ReplaceAll(s, repository.HighlightStop, "</mark>")
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+// sqlite has no text search, so these tests run the LIKE fallback
+
+func TestSearchLikeFallback(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	search := service.NewSearchService(repo)
+	if repo.FullTextSearch() {
+		t.Fatal("expected the LIKE fallback on sqlite")
+	}
+	due := time.Now().Add(time.Hour)
+	for _, task := range []*models.Task{
+		{Title: "Pay rent", Description: "Monthly rent to the landlord", DueAt: due},
+		{Title: "Rent a car", DueAt: due},
+		{Title: "Groceries", Description: "Save 50% on <fruit>", DueAt: due},
+	} {
+		if err := tasks.Create(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tasks.AddComment(3, "sam", "paid the rent already"); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := search.Search("RENT", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.FullText || page.Capped || page.Total != 3 || len(page.Results) != 3 {
+		t.Fatalf("got full_text %v capped %v total %d and %d results", page.FullText, page.Capped, page.Total, len(page.Results))
+	}
+	// title matches rank first
+	var got []string
+	for _, h := range page.Results {
+		got = append(got, fmt.Sprintf("%s %d %s", h.Kind, h.TaskID, h.Title))
+	}
+	want := []string{"task 1 Pay <mark>rent</mark>", "task 2 <mark>Rent</mark> a car", "comment 3 Groceries"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("results %q, want %q", got, want)
+	}
+	if s := page.Results[2].Snippet; s != "paid the <mark>rent</mark> already" {
+		t.Errorf("comment snippet %q", s)
+	}
+
+	// every word must occur
+	if page, _ = search.Search("pay rent", 10, 0); page.Total != 1 || page.Results[0].TaskID != 1 {
+		t.Errorf("pay rent: total %d", page.Total)
+	}
+	// LIKE wildcards are taken literally, and snippets are escaped
+	if page, _ = search.Search("50%", 10, 0); page.Total != 1 || page.Results[0].Snippet != "Save <mark>50%</mark> on &lt;fruit&gt;" {
+		t.Errorf("50%%: total %d %+v", page.Total, page.Results)
+	}
+	if page, _ = search.Search("5_", 10, 0); page.Total != 0 {
+		t.Errorf("5_ matched %d", page.Total)
+	}
+	// paging keeps the total
+	if page, _ = search.Search("rent", 1, 1); page.Total != 3 || len(page.Results) != 1 || page.Results[0].TaskID != 2 {
+		t.Errorf("second page: total %d %+v", page.Total, page.Results)
+	}
+	if page, _ = search.Search("rent", 10, 5); page.Total != 3 || len(page.Results) != 0 {
+		t.Errorf("past the end: total %d, %d results", page.Total, len(page.Results))
+	}
+}
+
+func TestSearchLikeCountsPastCap(t *testing.T) {
+	repo := newTestRepo(t)
+	search := service.NewSearchService(repo)
+	const n = 1200 // more than the fallback ranks
+	rows := make([]models.Task, n)
+	for i := range rows {
+		rows[i] = models.Task{Title: fmt.Sprintf("bulk item %d", i), Status: models.StatusTodo, DueAt: time.Now()}
+	}
+	if err := repo.DB.CreateInBatches(rows, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+	page, err := search.Search("bulk", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != n || !page.Capped || len(page.Results) != 10 {
+		t.Errorf("got total %d capped %v and %d results, want %d, true and 10", page.Total, page.Capped, len(page.Results), n)
+	}
+	if page, _ = search.Search("item 7", 10, 0); page.Capped {
+		t.Errorf("search under the cap reported capped (total %d)", page.Total)
+	}
+}
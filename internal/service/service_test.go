@@ -0,0 +1,13 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/repository/repotest"
+)
+
+func newTestRepo(t *testing.T) *repository.GormRepo {
+	t.Helper()
+	return repository.NewGormRepo(repotest.Open(t))
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SocketChannel delivers reminders to the assignee's connected /ws clients
+const SocketChannel = "ws"
+
+// Session lifetimes
+const (
+	DefaultSessionTTL = 30 * 24 * time.Hour
+	MaxSessionTTL     = 365 * 24 * time.Hour
+)
+
+// ResendUnacked is how far back Connect looks for reminders that were sent
+// to a socket but never acked
+const ResendUnacked = 24 * time.Hour
+
+// MaxSnooze caps how far a snooze sent over a socket may reach
+const MaxSnooze = 7 * 24 * time.Hour
+
+// Socket message types
+const (
+	MsgHello    = "hello"    // server: connection accepted
+	MsgReminder = "reminder" // server: a reminder for the connected user
+	MsgOK       = "ok"       // server: the client message Ref succeeded
+	MsgError    = "error"    // server: the client message Ref failed
+	MsgAck      = "ack"      // client: reminder ID was seen
+	MsgSnooze   = "snooze"   // client: snooze TaskID for Minutes
+	MsgDone     = "done"     // client: mark TaskID done
+)
+
+var (
+	ErrInvalidSession = errors.New("invalid or expired session")
+	ErrNotConnected   = errors.New("recipient not connected")
+)
+
+// SocketMessage is the JSON envelope of every /ws message. Clients may set
+// Ref on their messages; the ok/error reply carries it back.
+type SocketMessage struct {
+	Type    string       `json:"type"`
+	Ref     string       `json:"ref,omitempty"`
+	ID      uint         `json:"id,omitempty"`      // notification ID (reminder, ack)
+	TaskID  uint         `json:"task_id,omitempty"` // reminder, snooze, done
+	RuleID  uint         `json:"rule_id,omitempty"`
+	Minutes int          `json:"minutes,omitempty"` // snooze
+	Message string       `json:"message,omitempty"`
+	User    string       `json:"user,omitempty"` // hello
+	Task    *models.Task `json:"task,omitempty"` // ok reply to snooze and done
+	Error   string       `json:"error,omitempty"`
+	SentAt  *time.Time   `json:"sent_at,omitempty"` // reminder
+}
+
+// SocketClient is one connected socket; the connection writes everything
+// sent on Send and closes when Revoked is closed
+type SocketClient struct {
+	User      string
+	SessionID uint
+	Send      chan SocketMessage
+
+	revoked chan struct{}
+}
+
+// Revoked is closed when the client's session is revoked
+func (c *SocketClient) Revoked() <-chan struct{} {
+	return c.revoked
+}
+
+// push queues m without blocking; it reports false when the client's buffer
+// is full
+func (c *SocketClient) push(m SocketMessage) bool {
+	select {
+	case c.Send <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reply queues a reply to the client; a client too slow to take it misses it
+func (c *SocketClient) Reply(m SocketMessage) {
+	if !c.push(m) {
+		log.Warnf("[ws] dropping %s reply to %s: send buffer full", m.Type, c.User)
+	}
+}
+
+// SocketService manages /ws sessions and connected clients. It is the
+// notifier of SocketChannel: a reminder goes to every socket its recipient
+// has open, and fails (to be retried) while they have none.
+type SocketService struct {
+	repo       *repository.GormRepo
+	tasks      *TaskService
+	dispatcher *Dispatcher
+
+	mu      sync.Mutex
+	clients map[string]map[*SocketClient]struct{}
+}
+
+func NewSocketService(repo *repository.GormRepo, tasks *TaskService) *SocketService {
+	return &SocketService{repo: repo, tasks: tasks, clients: map[string]map[*SocketClient]struct{}{}}
+}
+
+// UseDispatcher lets reminders that failed while the user was offline be
+// retried as soon as they connect
+func (s *SocketService) UseDispatcher(d *Dispatcher) {
+	s.dispatcher = d
+}
+
+// CreateSession issues a token that lets user connect until it expires and
+// returns it in plain; it cannot be recovered later
+func (s *SocketService) CreateSession(user string, ttl time.Duration) (*models.SocketSession, string, error) {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return nil, "", fmt.Errorf("%w: user is required", ErrInvalidSession)
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	if ttl > MaxSessionTTL {
+		return nil, "", fmt.Errorf("%w: ttl exceeds %s", ErrInvalidSession, MaxSessionTTL)
+	}
+	token := NewCorrelationID() + NewCorrelationID()
+	sess := &models.SocketSession{User: user, TokenHash: HashToken(token), ExpiresAt: time.Now().Add(ttl)}
+	if err := s.repo.CreateSocketSession(sess); err != nil {
+		return nil, "", err
+	}
+	return sess, token, nil
+}
+
+// SessionByToken returns the unexpired session a token belongs to
+func (s *SocketService) SessionByToken(token string) (*models.SocketSession, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+	sess, err := s.repo.GetSocketSessionByTokenHash(HashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(sess.ExpiresAt) {
+		return nil, ErrInvalidSession
+	}
+	return sess, nil
+}
+
+// Connect registers a client for user and makes their undelivered socket
+// reminders due right away, including those sent to a socket in the last
+// ResendUnacked that were never acked
+func (s *SocketService) Connect(sess *models.SocketSession) *SocketClient {
+	user := sess.User
+	c := &SocketClient{User: user, SessionID: sess.ID, Send: make(chan SocketMessage, 64), revoked: make(chan struct{})}
+	s.mu.Lock()
+	if s.clients[user] == nil {
+		s.clients[user] = map[*SocketClient]struct{}{}
+	}
+	s.clients[user][c] = struct{}{}
+	s.mu.Unlock()
+
+	n, err := s.repo.RetryRecipientNow(user, SocketChannel, time.Now().Add(-ResendUnacked))
+	if err != nil {
+		log.Errorf("[ws] requeue reminders for %s: %v", user, err)
+	}
+	if n > 0 && s.dispatcher != nil {
+		s.dispatcher.Wake()
+	}
+	return c
+}
+
+func (s *SocketService) Disconnect(c *SocketClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients[c.User], c)
+	if len(s.clients[c.User]) == 0 {
+		delete(s.clients, c.User)
+	}
+}
+
+// RevokeSession deletes a session and closes the sockets opened with it;
+// it returns how many were open
+func (s *SocketService) RevokeSession(id uint) (int, error) {
+	if err := s.repo.DeleteSocketSession(id); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closed := 0
+	for _, cs := range s.clients {
+		for c := range cs {
+			if c.SessionID == id {
+				// clients stay registered until their connection ends, so
+				// a client can only be revoked once
+				select {
+				case <-c.revoked:
+				default:
+					close(c.revoked)
+					closed++
+				}
+			}
+		}
+	}
+	return closed, nil
+}
+
+// Connected returns how many sockets each user has open
+func (s *SocketService) Connected() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]int{}
+	for user, cs := range s.clients {
+		out[user] = len(cs)
+	}
+	return out
+}
+
+func (s *SocketService) Channel() string { return SocketChannel }
+
+// Notify pushes the reminder to every socket of its recipient. A push only
+// queues it; if no ack comes back, Connect requeues it on the next connect.
+func (s *SocketService) Notify(_ context.Context, n *models.Notification) error {
+	if n.Recipient == "" {
+		return errors.New("notification has no recipient")
+	}
+	now := time.Now()
+	m := SocketMessage{Type: MsgReminder, ID: n.ID, TaskID: n.TaskID, RuleID: n.RuleID, Message: n.Message, SentAt: &now}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients[n.Recipient]) == 0 {
+		return ErrNotConnected
+	}
+	delivered := false
+	for c := range s.clients[n.Recipient] {
+		if c.push(m) {
+			delivered = true
+		}
+	}
+	if !delivered {
+		return fmt.Errorf("%w: every socket of %s is backed up", ErrNotConnected, n.Recipient)
+	}
+	return nil
+}
+
+// Handle runs one message received from c and returns the reply
+func (s *SocketService) Handle(c *SocketClient, raw []byte) SocketMessage {
+	var in SocketMessage
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return SocketMessage{Type: MsgError, Error: "invalid message: " + err.Error()}
+	}
+	reply, err := s.handle(c, &in)
+	if err != nil {
+		return SocketMessage{Type: MsgError, Ref: in.Ref, Error: err.Error()}
+	}
+	reply.Type, reply.Ref = MsgOK, in.Ref
+	return reply
+}
+
+func (s *SocketService) handle(c *SocketClient, in *SocketMessage) (SocketMessage, error) {
+	switch in.Type {
+	case MsgAck:
+		ok, err := s.repo.AckNotification(in.ID, c.User, time.Now())
+		if err != nil {
+			return SocketMessage{}, err
+		}
+		if !ok {
+			return SocketMessage{}, fmt.Errorf("reminder %d not found or already acknowledged", in.ID)
+		}
+		_ = s.repo.WriteAudit("reminder.ack", fmt.Sprintf("notification #%d by %s", in.ID, c.User))
+		return SocketMessage{ID: in.ID}, nil
+	case MsgSnooze:
+		if in.Minutes <= 0 || time.Duration(in.Minutes)*time.Minute > MaxSnooze {
+			return SocketMessage{}, fmt.Errorf("minutes must be between 1 and %d", int(MaxSnooze.Minutes()))
+		}
+		if err := s.checkAssignee(in.TaskID, c.User); err != nil {
+			return SocketMessage{}, err
+		}
+		t, err := s.tasks.Snooze(in.TaskID, time.Now().Add(time.Duration(in.Minutes)*time.Minute))
+		if err != nil {
+			return SocketMessage{}, err
+		}
+		_ = s.repo.WriteTaskAudit(t.ID, "task.snooze", fmt.Sprintf("%s until %s by %s", t.Title, t.SnoozedUntil.Format(time.RFC3339), c.User))
+		return SocketMessage{TaskID: t.ID, Task: t}, nil
+	case MsgDone:
+		if err := s.checkAssignee(in.TaskID, c.User); err != nil {
+			return SocketMessage{}, err
+		}
+		t, from, err := s.tasks.TransitionAs(in.TaskID, models.StatusDone, 0, c.User+" via socket")
+		if err != nil {
+			return SocketMessage{}, err
+		}
+		_ = s.repo.WriteTaskAudit(t.ID, "task.transition", fmt.Sprintf("%s (%s -> %s) by %s", t.Title, from, t.Status, c.User))
+		return SocketMessage{TaskID: t.ID, Task: t}, nil
+	}
+	return SocketMessage{}, fmt.Errorf("unknown message type %q", in.Type)
+}
+
+// checkAssignee lets users act only on the tasks they are reminded about
+func (s *SocketService) checkAssignee(taskID uint, user string) error {
+	t, err := s.repo.GetTaskByID(taskID)
+	if err != nil || t.Assignee != user {
+		return fmt.Errorf("task %d not found", taskID)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestConnectResendsUnackedReminders(t *testing.T) {
+	repo := newTestRepo(t)
+	svc := service.NewSocketService(repo, nil)
+	now := time.Now()
+	old := now.Add(-2 * service.ResendUnacked)
+
+	notify := func(status, recipient string, sentAt, ackedAt, nextAt *time.Time) uint {
+		t.Helper()
+		n := models.Notification{Channel: service.SocketChannel, Recipient: recipient, Message: "m"}
+		if err := repo.CreateNotification(&n); err != nil {
+			t.Fatal(err)
+		}
+		err := repo.DB.Model(&n).Updates(map[string]any{"status": status, "sent_at": sentAt, "acked_at": ackedAt, "next_attempt_at": nextAt}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.ID
+	}
+	later := now.Add(time.Hour)
+	unacked := notify(models.NotificationSent, "ann", &now, nil, nil)
+	acked := notify(models.NotificationSent, "ann", &now, &now, nil)
+	stale := notify(models.NotificationSent, "ann", &old, nil, nil)
+	waiting := notify(models.NotificationPending, "ann", nil, nil, &later)
+	other := notify(models.NotificationSent, "bob", &now, nil, nil)
+
+	c := svc.Connect(&models.SocketSession{ID: 1, User: "ann"})
+	defer svc.Disconnect(c)
+
+	want := map[uint]string{
+		unacked: models.NotificationPending,
+		acked:   models.NotificationSent,
+		stale:   models.NotificationSent,
+		waiting: models.NotificationPending,
+		other:   models.NotificationSent,
+	}
+	for id, status := range want {
+		var n models.Notification
+		if err := repo.DB.First(&n, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if n.Status != status {
+			t.Errorf("notification %d: status %q, want %q", id, n.Status, status)
+		}
+		if status == models.NotificationPending && (n.NextAttemptAt != nil || n.SentAt != nil) {
+			t.Errorf("notification %d not due now: next_attempt_at %v sent_at %v", id, n.NextAttemptAt, n.SentAt)
+		}
+	}
+}
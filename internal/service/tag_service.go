@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrTagExists is returned when a tag name is already taken
+var ErrTagExists = errors.New("tag already exists")
+
+// checkTagName normalizes name and makes sure no other tag than selfID uses it
+func (s *TaskService) checkTagName(name string, selfID uint) (string, error) {
+	n, ok := models.NormalizeTagName(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q (1 to %d characters, no commas)", ErrInvalidTag, name, models.MaxTagLength)
+	}
+	other, err := s.repo.GetTagByName(n)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return n, nil
+	case err != nil:
+		return "", err
+	case other.ID != selfID:
+		return "", fmt.Errorf("%w: %s", ErrTagExists, n)
+	}
+	return n, nil
+}
+
+// CreateTag adds a tag that is not on any task yet
+func (s *TaskService) CreateTag(name string) (*models.Tag, error) {
+	n, err := s.checkTagName(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	tag := &models.Tag{Name: n}
+	if err := s.repo.CreateTag(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// RenameTag renames a tag on every task carrying it and in the remind_tags
+// of every rule reminding on it
+func (s *TaskService) RenameTag(id uint, name string) (*models.Tag, string, error) {
+	tag, err := s.repo.GetTagByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	n, err := s.checkTagName(name, id)
+	if err != nil {
+		return nil, "", err
+	}
+	old := tag.Name
+	if n == old {
+		return tag, old, nil
+	}
+	tag.Name = n
+	if err := s.repo.RenameTag(tag); err != nil {
+		return nil, "", err
+	}
+	s.tagChanged(id)
+	return tag, old, nil
+}
+
+// DeleteTag removes a tag from every task and rule and deletes it; it fails
+// with repository.ErrTagInUse while a rule reminds on no other tag
+func (s *TaskService) DeleteTag(id uint) (*models.Tag, error) {
+	tag, err := s.repo.GetTagByID(id)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.repo.TaggedTaskIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteTag(id); err != nil {
+		return nil, err
+	}
+	for _, taskID := range ids {
+		s.changed(taskID)
+	}
+	return tag, nil
+}
+
+// tagChanged tells observers about every task carrying the tag, since rules
+// with remind_tags may now apply to them or not
+func (s *TaskService) tagChanged(id uint) {
+	ids, err := s.repo.TaggedTaskIDs(id)
+	if err != nil {
+		return
+	}
+	for _, taskID := range ids {
+		s.changed(taskID)
+	}
+}
@@ -0,0 +1,120 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+// tagFixture creates the tags billing, urgent and misc on a task each, and
+// rules reminding on them
+type tagFixture struct {
+	repo  *repository.GormRepo
+	tasks *service.TaskService
+	tags  map[string]uint
+}
+
+func newTagFixture(t *testing.T) *tagFixture {
+	t.Helper()
+	repo := newTestRepo(t)
+	f := &tagFixture{repo: repo, tasks: service.NewTaskService(repo), tags: map[string]uint{}}
+	for _, name := range []string{"billing", "urgent", "misc"} {
+		task := models.Task{Title: name, DueAt: time.Now().Add(time.Hour), Tags: []models.Tag{{Name: name}}}
+		if err := f.tasks.Create(&task); err != nil {
+			t.Fatal(err)
+		}
+		f.tags[name] = task.Tags[0].ID
+	}
+	return f
+}
+
+func (f *tagFixture) rule(t *testing.T, remindTags string) *models.ReminderRule {
+	t.Helper()
+	rr := &models.ReminderRule{Name: remindTags, Active: true, RuleType: "at_due", Params: "{}", RemindTags: remindTags}
+	if err := f.repo.CreateRule(rr); err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+// reload returns the rule as stored
+func (f *tagFixture) reload(t *testing.T, rr *models.ReminderRule) *models.ReminderRule {
+	t.Helper()
+	got, err := f.repo.GetRuleByID(rr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestRenameTagUpdatesRules(t *testing.T) {
+	f := newTagFixture(t)
+	both := f.rule(t, "billing,urgent")
+	// a tag name that merely contains the renamed one is left alone
+	similar := f.rule(t, "billings,urgent")
+	// renaming onto a name the rule already lists does not duplicate it
+	f.rule(t, "finance")
+	overlap := f.rule(t, "billing,finance")
+
+	if _, _, err := f.tasks.RenameTag(f.tags["billing"], "Finance"); err != nil {
+		t.Fatal(err)
+	}
+	for rr, want := range map[*models.ReminderRule]string{
+		both:    "finance,urgent",
+		similar: "billings,urgent",
+		overlap: "finance",
+	} {
+		got := f.reload(t, rr)
+		if got.RemindTags != want {
+			t.Errorf("rule %q: remind_tags %q, want %q", rr.Name, got.RemindTags, want)
+		}
+		if changed := want != rr.RemindTags; changed && got.Version != rr.Version+1 {
+			t.Errorf("rule %q: version %d, want %d", rr.Name, got.Version, rr.Version+1)
+		}
+	}
+}
+
+func TestDeleteTagUpdatesRules(t *testing.T) {
+	f := newTagFixture(t)
+	both := f.rule(t, "billing,urgent")
+	only := f.rule(t, "urgent")
+
+	// deleting urgent would make the second rule remind every task
+	_, err := f.tasks.DeleteTag(f.tags["urgent"])
+	if !errors.Is(err, repository.ErrTagInUse) {
+		t.Fatalf("DeleteTag(urgent) = %v, want ErrTagInUse", err)
+	}
+	if _, err := f.repo.GetTagByName("urgent"); err != nil {
+		t.Errorf("urgent was deleted anyway: %v", err)
+	}
+	if got := f.reload(t, both); got.RemindTags != "billing,urgent" {
+		t.Errorf("failed delete changed remind_tags to %q", got.RemindTags)
+	}
+
+	if _, err := f.tasks.DeleteTag(f.tags["billing"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.reload(t, both); got.RemindTags != "urgent" || got.Version != both.Version+1 {
+		t.Errorf("remind_tags %q version %d, want urgent and %d", got.RemindTags, got.Version, both.Version+1)
+	}
+	if got := f.reload(t, only); got.RemindTags != "urgent" || got.Version != only.Version {
+		t.Errorf("unrelated rule changed: %q version %d", got.RemindTags, got.Version)
+	}
+}
+
+func TestListDeduplicatesTags(t *testing.T) {
+	f := newTagFixture(t)
+	for _, tags := range [][]string{{"billing"}, {"billing", "billing"}, {"billing", " Billing "}} {
+		list, err := f.tasks.List(tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Title != "billing" {
+			t.Errorf("List(%q) returned %d tasks, want the billing task", tags, len(list))
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+)
+
+// MaxBatchSize caps the number of operations in one batch request
+const MaxBatchSize = 1000
+
+// Batch operation kinds
+const (
+	BatchCreate   = "create"
+	BatchUpdate   = "update"
+	BatchComplete = "complete"
+	BatchDelete   = "delete"
+)
+
+var (
+	ErrBatchEmpty    = errors.New("batch has no operations")
+	ErrBatchTooLarge = fmt.Errorf("batch exceeds %d operations", MaxBatchSize)
+	ErrBatchFailed   = errors.New("batch failed; no changes were applied")
+)
+
+// BatchOp is one operation of a batch request. Version conditions the write
+// like an If-Match header; it is required for updates and optional for
+// complete and delete. The task of an update is a JSON Merge Patch, so fields
+// it leaves out keep their stored values.
+type BatchOp struct {
+	Op      string       `json:"op"`
+	ID      uint         `json:"id,omitempty"`
+	Version uint         `json:"version,omitempty"`
+	Task    *models.Task `json:"task,omitempty"`
+
+	patch map[string]any // the task object as sent, for updates
+}
+
+// UnmarshalJSON keeps the raw task object next to the decoded one
+func (op *BatchOp) UnmarshalJSON(data []byte) error {
+	type plain BatchOp
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	var raw struct {
+		Task map[string]any `json:"task"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*op = BatchOp(p)
+	op.patch = raw.Task
+	return nil
+}
+
+// BatchResult reports the outcome of one operation
+type BatchResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	ID     uint         `json:"id,omitempty"`
+	Status string       `json:"status"` // "ok", "error", "rolled_back", "skipped"
+	Error  string       `json:"error,omitempty"`
+	Task   *models.Task `json:"task,omitempty"`
+
+	completed  bool   // the operation moved the task to done
+	dependents []uint // tasks waiting on this one that became blocked or unblocked
+}
+
+// NewCorrelationID returns a random ID used to group audit entries
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Batch applies all operations in one transaction. Either every operation
+// succeeds, or nothing is written and ErrBatchFailed is returned together with
+// per-item results pointing at the failing operation. Each applied operation
+// gets its own audit entry tagged with correlationID.
+func (s *TaskService) Batch(ops []BatchOp, correlationID string) ([]BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(ops))
+	failed := -1
+	err := s.repo.Transaction(func(repo *repository.GormRepo) error {
+		tx := &TaskService{repo: repo} // no observers until commit
+		for i, op := range ops {
+			res, err := tx.applyBatchOp(op, correlationID)
+			res.Index, res.Op = i, op.Op
+			if err != nil {
+				res.Status, res.Error = "error", err.Error()
+				results[i] = res
+				failed = i
+				return err
+			}
+			res.Status = "ok"
+			results[i] = res
+		}
+		return nil
+	})
+	if err == nil {
+		// observers only hear about committed writes
+		for i, res := range results {
+			if ops[i].Op == BatchDelete {
+				s.deleted(res.ID)
+			} else {
+				s.changed(res.ID)
+			}
+			for _, dep := range res.dependents {
+				s.changed(dep)
+			}
+			if res.completed {
+				s.completed(res.Task)
+			}
+		}
+		return results, nil
+	}
+
+	for i := range results {
+		switch {
+		case i == failed:
+		case failed >= 0 && i < failed:
+			results[i].Status, results[i].Task = "rolled_back", nil
+		default:
+			results[i] = BatchResult{Index: i, Op: ops[i].Op, ID: ops[i].ID, Status: "skipped"}
+		}
+	}
+	if failed < 0 {
+		// commit itself failed
+		return results, err
+	}
+	return results, fmt.Errorf("%w: operation %d: %v", ErrBatchFailed, failed, err)
+}
+
+func (s *TaskService) applyBatchOp(op BatchOp, correlationID string) (BatchResult, error) {
+	res := BatchResult{ID: op.ID}
+	switch op.Op {
+	case BatchCreate:
+		if op.Task == nil {
+			return res, errors.New("create requires task")
+		}
+		task := *op.Task
+		task.ID = 0
+		if err := s.Create(&task); err != nil {
+			return res, err
+		}
+		res.ID, res.Task = task.ID, &task
+		return res, s.repo.WriteTaskAuditCorrelated(task.ID, "task.create", task.Title, correlationID)
+
+	case BatchUpdate:
+		if op.ID == 0 || op.patch == nil {
+			return res, errors.New("update requires id and task")
+		}
+		if op.Version == 0 {
+			return res, errors.New("update requires version")
+		}
+		for _, f := range TaskReadOnlyFields {
+			if _, ok := op.patch[f]; ok {
+				return res, fmt.Errorf("field %s is read-only", f)
+			}
+		}
+		current, err := s.repo.GetTaskByID(op.ID)
+		if err != nil {
+			return res, err
+		}
+		task, err := ApplyMergePatch(current, op.patch)
+		if err != nil {
+			return res, err
+		}
+		task.ID = op.ID
+		task.Version = op.Version
+		if err := s.Update(task); err != nil {
+			return res, err
+		}
+		res.Task = task
+		res.completed = task.Status == models.StatusDone && current.Status != models.StatusDone
+		if task.IsOpen() != current.IsOpen() {
+			if res.dependents, err = s.repo.DependentTaskIDs(task.ID); err != nil {
+				return res, err
+			}
+		}
+		return res, s.repo.WriteTaskAuditCorrelated(task.ID, "task.update", fmt.Sprintf("%s (status %s)", task.Title, task.Status), correlationID)
+
+	case BatchComplete:
+		if op.ID == 0 {
+			return res, errors.New("complete requires id")
+		}
+		task, from, err := s.Transition(op.ID, models.StatusDone, op.Version)
+		if err != nil {
+			return res, err
+		}
+		res.Task = task
+		res.completed = true
+		if (&models.Task{Status: from}).IsOpen() {
+			if res.dependents, err = s.repo.DependentTaskIDs(task.ID); err != nil {
+				return res, err
+			}
+		}
+		return res, s.repo.WriteTaskAuditCorrelated(task.ID, "task.transition", fmt.Sprintf("%s (%s -> %s)", task.Title, from, task.Status), correlationID)
+
+	case BatchDelete:
+		if op.ID == 0 {
+			return res, errors.New("delete requires id")
+		}
+		task, err := s.repo.GetTaskByID(op.ID)
+		if err != nil {
+			return res, err
+		}
+		version := task.Version
+		if op.Version != 0 {
+			version = op.Version
+		}
+		if res.dependents, err = s.repo.DependentTaskIDs(op.ID); err != nil {
+			return res, err
+		}
+		if err := s.Delete(op.ID, version); err != nil {
+			return res, err
+		}
+		return res, s.repo.WriteTaskAuditCorrelated(task.ID, "task.delete", task.Title, correlationID)
+	}
+	return res, fmt.Errorf("unknown op %q", op.Op)
+}
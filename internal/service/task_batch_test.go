@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func batchOps(t *testing.T, src string) []service.BatchOp {
+	t.Helper()
+	var ops []service.BatchOp
+	if err := json.Unmarshal([]byte(src), &ops); err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestBatchUpdateKeepsOmittedFields(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	parent := newTask(t, tasks, "parent", models.StatusTodo, nil)
+	due := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	task := &models.Task{Title: "child", Description: "details", Assignee: "alice", DueAt: due, ParentID: &parent.ID}
+	if err := tasks.Create(task); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := batchOps(t, fmt.Sprintf(`[{"op":"update","id":%d,"version":%d,"task":{"status":"in_progress"}}]`, task.ID, task.Version))
+	if _, err := tasks.Batch(ops, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tasks.Get(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.StatusInProgress {
+		t.Errorf("status = %q, want %q", got.Status, models.StatusInProgress)
+	}
+	if got.Title != "child" || got.Description != "details" || got.Assignee != "alice" ||
+		!got.DueAt.Equal(due) || got.ParentID == nil || *got.ParentID != parent.ID {
+		t.Errorf("omitted fields changed: %+v", got)
+	}
+}
+
+func TestBatchUpdateRequiresVersion(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	task := newTask(t, tasks, "task", models.StatusTodo, nil)
+
+	for _, src := range []string{
+		fmt.Sprintf(`[{"op":"update","id":%d,"task":{"title":"renamed"}}]`, task.ID),
+		fmt.Sprintf(`[{"op":"update","id":%d,"version":%d,"task":{"title":"renamed"}}]`, task.ID, task.Version+1),
+		fmt.Sprintf(`[{"op":"update","id":%d,"version":%d,"task":{"version":9}}]`, task.ID, task.Version),
+	} {
+		if _, err := tasks.Batch(batchOps(t, src), "c1"); !errors.Is(err, service.ErrBatchFailed) {
+			t.Errorf("Batch(%s) = %v, want ErrBatchFailed", src, err)
+		}
+	}
+	got, err := tasks.Get(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "task" || got.Version != task.Version {
+		t.Errorf("task = %q at version %d, want it untouched", got.Title, got.Version)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+)
+
+// Import formats
+const (
+	ImportCSV  = "csv"
+	ImportJSON = "json"
+)
+
+// importLocalLayouts are accepted due date layouts without a UTC offset; they
+// are resolved in the row's timezone (or the import default)
+var importLocalLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ImportRecord is one raw row of an import file
+type ImportRecord struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	DueAt       string   `json:"due_at"`
+	Timezone    string   `json:"timezone"`
+	Status      string   `json:"status"`
+	Tags        []string `json:"tags"`
+}
+
+// ImportError describes why a row was rejected
+type ImportError struct {
+	Row   int    `json:"row"` // 1-based, header excluded
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarizes an import run
+type ImportReport struct {
+	DryRun        bool          `json:"dry_run"`
+	Total         int           `json:"total"`
+	Accepted      int           `json:"accepted"`
+	Rejected      int           `json:"rejected"`
+	CorrelationID string        `json:"correlation_id,omitempty"`
+	Errors        []ImportError `json:"errors"`
+	Tasks         []models.Task `json:"tasks"`
+}
+
+// ParseImport reads CSV (with a header row) or a JSON array of ImportRecord
+func ParseImport(r io.Reader, format string) ([]ImportRecord, error) {
+	switch format {
+	case ImportJSON:
+		var recs []ImportRecord
+		if err := json.NewDecoder(r).Decode(&recs); err != nil {
+			return nil, fmt.Errorf("invalid JSON import: %w", err)
+		}
+		return recs, nil
+	case ImportCSV:
+		return parseImportCSV(r)
+	}
+	return nil, fmt.Errorf("unsupported import format %q (want csv or json)", format)
+}
+
+func parseImportCSV(r io.Reader) ([]ImportRecord, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		return nil, errors.New("CSV header must include a title column")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var recs []ImportRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		recs = append(recs, ImportRecord{
+			Title:       get(row, "title"),
+			Description: get(row, "description"),
+			DueAt:       get(row, "due_at"),
+			Timezone:    get(row, "timezone"),
+			Status:      get(row, "status"),
+			Tags:        splitTags(get(row, "tags")),
+		})
+	}
+	return recs, nil
+}
+
+// splitTags splits a CSV tags cell on commas or semicolons
+func splitTags(s string) []string {
+	var out []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// validateImportRecord turns a raw record into a task, collecting every problem
+func validateImportRecord(row int, rec ImportRecord, defaultLoc *time.Location) (models.Task, []ImportError) {
+	var errs []ImportError
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, ImportError{Row: row, Field: field, Error: fmt.Sprintf(format, args...)})
+	}
+
+	task := models.Task{
+		Title:       strings.TrimSpace(rec.Title),
+		Description: strings.TrimSpace(rec.Description),
+		Status:      strings.TrimSpace(rec.Status),
+	}
+	if task.Title == "" {
+		fail("title", "title is required")
+	}
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	} else if !models.ValidStatus(task.Status) {
+		fail("status", "unknown status %q", task.Status)
+	}
+
+	loc := defaultLoc
+	if tz := strings.TrimSpace(rec.Timezone); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			fail("timezone", "unknown timezone %q", tz)
+		} else {
+			loc = l
+		}
+	}
+	due, err := parseImportDue(strings.TrimSpace(rec.DueAt), loc)
+	if err != nil {
+		fail("due_at", "%v", err)
+	}
+	task.DueAt = due
+
+	for _, t := range rec.Tags {
+		name, ok := models.NormalizeTagName(t)
+		if !ok {
+			fail("tags", "invalid tag %q (1 to %d characters)", t, models.MaxTagLength)
+			break
+		}
+		task.Tags = append(task.Tags, models.Tag{Name: name})
+	}
+	return task, errs
+}
+
+func parseImportDue(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("due_at is required")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range importLocalLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse due_at %q (use RFC 3339 or YYYY-MM-DD HH:MM with a timezone)", s)
+}
+
+// Import validates every record and, unless dryRun, creates the accepted ones
+// in a single transaction, each with an audit entry under one correlation ID:
+// either every accepted row is imported or none is.
+// Rows without a timezone or UTC offset are resolved in defaultLoc.
+func (s *TaskService) Import(recs []ImportRecord, defaultLoc *time.Location, dryRun bool, correlationID string) (*ImportReport, error) {
+	if defaultLoc == nil {
+		defaultLoc = time.UTC
+	}
+	report := &ImportReport{DryRun: dryRun, Total: len(recs), Errors: []ImportError{}, Tasks: []models.Task{}}
+
+	var accepted []models.Task
+	var rows []int
+	for i, rec := range recs {
+		task, errs := validateImportRecord(i+1, rec, defaultLoc)
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, errs...)
+			report.Rejected++
+			continue
+		}
+		accepted = append(accepted, task)
+		rows = append(rows, i+1)
+	}
+	report.Accepted = len(accepted)
+	if dryRun {
+		report.Tasks = accepted
+		return report, nil
+	}
+	if len(accepted) == 0 {
+		return report, nil
+	}
+
+	report.CorrelationID = correlationID
+	var created []models.Task
+	err := s.repo.Transaction(func(repo *repository.GormRepo) error {
+		tx := &TaskService{repo: repo} // no observers until commit
+		for i := range accepted {
+			res, err := tx.applyBatchOp(BatchOp{Op: BatchCreate, Task: &accepted[i]}, correlationID)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", rows[i], err)
+			}
+			created = append(created, *res.Task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import failed, no tasks were created: %w", err)
+	}
+	for i := range created {
+		s.changed(created[i].ID)
+	}
+	report.Tasks = created
+	_ = s.repo.WriteAuditCorrelated("task.import",
+		fmt.Sprintf("imported %d of %d tasks (%d rejected)", report.Accepted, report.Total, report.Rejected), correlationID)
+	return report, nil
+}
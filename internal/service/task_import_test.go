@@ -0,0 +1,46 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+)
+
+func TestImportIsAtomic(t *testing.T) {
+	repo := newTestRepo(t)
+	svc := service.NewTaskService(repo)
+	// make the database refuse one row that passes validation
+	if err := repo.DB.Exec(`CREATE TRIGGER refuse_boom BEFORE INSERT ON tasks WHEN NEW.title = 'boom'
+		BEGIN SELECT RAISE(ABORT, 'refused'); END`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	recs := make([]service.ImportRecord, 0, service.MaxBatchSize+2)
+	for i := 0; i < service.MaxBatchSize+1; i++ {
+		recs = append(recs, service.ImportRecord{Title: "ok", DueAt: "2030-01-01 09:00"})
+	}
+	recs = append(recs, service.ImportRecord{Title: "boom", DueAt: "2030-01-01 09:00"})
+
+	if _, err := svc.Import(recs, nil, false, "c1"); err == nil || !strings.Contains(err.Error(), "row 1002") {
+		t.Fatalf("Import error = %v, want a failure naming row 1002", err)
+	}
+	var n int64
+	repo.DB.Model(&models.Task{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d tasks left after a failed import, want 0", n)
+	}
+	repo.DB.Model(&models.AuditLog{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d audit entries left after a failed import, want 0", n)
+	}
+
+	report, err := svc.Import(recs[:3], nil, false, "c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Accepted != 3 || len(report.Tasks) != 3 {
+		t.Errorf("report = %d accepted, %d tasks; want 3, 3", report.Accepted, len(report.Tasks))
+	}
+}
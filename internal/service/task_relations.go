@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRelation = errors.New("invalid task relation")
+	ErrOpenSubtasks    = errors.New("task has open subtasks")
+	ErrParentDone      = errors.New("parent task is done")
+)
+
+// maxTaskDepth bounds walks up the parent chain
+const maxTaskDepth = 1000
+
+// checkParent makes sure task.ParentID names an existing task that is not
+// the task itself or one of its subtasks
+func (s *TaskService) checkParent(task *models.Task) error {
+	if task.ParentID == nil {
+		return nil
+	}
+	id := *task.ParentID
+	if id == task.ID {
+		return fmt.Errorf("%w: a task cannot be its own parent", ErrInvalidRelation)
+	}
+	for depth := 0; depth < maxTaskDepth; depth++ {
+		parent, err := s.repo.ParentIDOf(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) && id == *task.ParentID {
+			return fmt.Errorf("%w: parent task %d not found", ErrInvalidRelation, id)
+		}
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			return nil
+		}
+		if *parent == task.ID {
+			return fmt.Errorf("%w: task %d is a subtask of task %d", ErrInvalidRelation, *task.ParentID, task.ID)
+		}
+		id = *parent
+	}
+	return fmt.Errorf("%w: subtasks nested deeper than %d", ErrInvalidRelation, maxTaskDepth)
+}
+
+// withSubtaskLock runs fn, which checks and writes task, in a transaction
+// holding the subtask lock when task is an open subtask or is being
+// completed, so checkParentOpen and checkSubtasks cannot both pass for
+// writes that together leave an open subtask under a done parent
+func (s *TaskService) withSubtaskLock(task *models.Task, fn func(tx *TaskService) error) error {
+	if task.Status != models.StatusDone && (task.ParentID == nil || !task.IsOpen()) {
+		return fn(s)
+	}
+	return s.repo.Transaction(func(repo *repository.GormRepo) error {
+		if err := repo.LockSubtasks(); err != nil {
+			return err
+		}
+		return fn(&TaskService{repo: repo})
+	})
+}
+
+// checkParentOpen refuses open subtasks under a done parent: creating one,
+// moving an open task under one, or reopening one. current is the stored
+// task, or nil for a new one.
+func (s *TaskService) checkParentOpen(task, current *models.Task) error {
+	if task.ParentID == nil || !task.IsOpen() {
+		return nil
+	}
+	if current != nil && current.IsOpen() && current.ParentID != nil && *current.ParentID == *task.ParentID {
+		return nil
+	}
+	status, err := s.repo.TaskStatus(*task.ParentID)
+	if err != nil {
+		return err
+	}
+	if status == models.StatusDone {
+		return fmt.Errorf("%w: reopen task %d before adding open subtasks to it", ErrParentDone, *task.ParentID)
+	}
+	return nil
+}
+
+// checkSubtasks refuses to complete a task while any of its subtasks is open
+func (s *TaskService) checkSubtasks(id uint, to string) error {
+	if to != models.StatusDone {
+		return nil
+	}
+	n, err := s.repo.CountOpenSubtasks(id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %d still open", ErrOpenSubtasks, n)
+	}
+	return nil
+}
+
+// dependentsChanged tells observers about the tasks waiting on id, which
+// become blocked or unblocked when it is reopened or finished
+func (s *TaskService) dependentsChanged(id uint) {
+	ids, err := s.repo.DependentTaskIDs(id)
+	if err != nil {
+		return
+	}
+	for _, dep := range ids {
+		s.changed(dep)
+	}
+}
+
+// Subtasks returns the direct subtasks of a task
+func (s *TaskService) Subtasks(id uint) ([]models.Task, error) {
+	if _, err := s.repo.GetTaskByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListSubtasks(id)
+}
+
+// AddDependency makes task id wait for task dependsOn. Dependencies that
+// would form a cycle are rejected; the check and the insert hold the
+// dependency lock, so concurrent additions cannot form one either.
+func (s *TaskService) AddDependency(id, dependsOn uint) (*models.Task, error) {
+	if id == dependsOn {
+		return nil, fmt.Errorf("%w: a task cannot depend on itself", ErrInvalidRelation)
+	}
+	err := s.repo.Transaction(func(repo *repository.GormRepo) error {
+		if err := repo.LockDependencies(); err != nil {
+			return err
+		}
+		if _, err := repo.TaskStatus(id); err != nil {
+			return err
+		}
+		if _, err := repo.TaskStatus(dependsOn); errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: task %d not found", ErrInvalidRelation, dependsOn)
+		} else if err != nil {
+			return err
+		}
+
+		// walk everything dependsOn waits for; reaching id would close a cycle
+		seen := map[uint]bool{dependsOn: true}
+		frontier := []uint{dependsOn}
+		for len(frontier) > 0 {
+			next, err := repo.DependencyIDs(frontier)
+			if err != nil {
+				return err
+			}
+			if slices.Contains(next, id) {
+				return fmt.Errorf("%w: task %d already waits for task %d", ErrInvalidRelation, dependsOn, id)
+			}
+			frontier = frontier[:0]
+			for _, n := range next {
+				if !seen[n] {
+					seen[n] = true
+					frontier = append(frontier, n)
+				}
+			}
+		}
+		return repo.AddDependency(id, dependsOn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.changed(id)
+	return s.repo.GetTaskByID(id)
+}
+
+// RemoveDependency drops a dependency; gorm.ErrRecordNotFound is returned
+// if there was none
+func (s *TaskService) RemoveDependency(id, dependsOn uint) (*models.Task, error) {
+	removed, err := s.repo.RemoveDependency(id, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, gorm.ErrRecordNotFound
+	}
+	s.changed(id)
+	return s.repo.GetTaskByID(id)
+}
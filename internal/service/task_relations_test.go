@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/service"
+	"gorm.io/gorm"
+)
+
+func newTask(t *testing.T, tasks *service.TaskService, title, status string, parent *models.Task) *models.Task {
+	t.Helper()
+	task := &models.Task{Title: title, Status: status, DueAt: time.Now().Add(time.Hour)}
+	if parent != nil {
+		task.ParentID = &parent.ID
+	}
+	if err := tasks.Create(task); err != nil {
+		t.Fatalf("create %s: %v", title, err)
+	}
+	return task
+}
+
+func TestNoOpenSubtasksUnderDoneParent(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	done := newTask(t, tasks, "done parent", models.StatusDone, nil)
+	open := newTask(t, tasks, "open parent", models.StatusTodo, nil)
+
+	// a new open subtask
+	err := tasks.Create(&models.Task{Title: "late", ParentID: &done.ID, DueAt: time.Now()})
+	if !errors.Is(err, service.ErrParentDone) {
+		t.Errorf("create open subtask of done task = %v, want ErrParentDone", err)
+	}
+	newTask(t, tasks, "finished subtask", models.StatusDone, done)
+
+	// moving an open task under the done parent
+	child := newTask(t, tasks, "child", models.StatusTodo, open)
+	moved := *child
+	moved.ParentID = &done.ID
+	if err := tasks.Update(&moved); !errors.Is(err, service.ErrParentDone) {
+		t.Errorf("re-parent onto done task = %v, want ErrParentDone", err)
+	}
+
+	// reopening a finished subtask of the done parent
+	finished := newTask(t, tasks, "finished", models.StatusDone, done)
+	if _, _, err := tasks.Transition(finished.ID, models.StatusTodo, 0); !errors.Is(err, service.ErrParentDone) {
+		t.Errorf("reopen subtask of done task = %v, want ErrParentDone", err)
+	}
+	reopened := *finished
+	reopened.Status = models.StatusTodo
+	if err := tasks.Update(&reopened); !errors.Is(err, service.ErrParentDone) {
+		t.Errorf("reopen subtask of done task by update = %v, want ErrParentDone", err)
+	}
+
+	// once the parent is reopened, so can its subtasks be
+	if _, _, err := tasks.Transition(done.ID, models.StatusTodo, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tasks.Transition(finished.ID, models.StatusTodo, 0); err != nil {
+		t.Errorf("reopen subtask of reopened task: %v", err)
+	}
+}
+
+func TestEditOpenSubtaskOfDoneParent(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	parent := newTask(t, tasks, "parent", models.StatusTodo, nil)
+	child := newTask(t, tasks, "child", models.StatusTodo, parent)
+	// rows written before the check existed
+	if err := repo.DB.Model(&models.Task{}).Where("id = ?", parent.ID).Update("status", models.StatusDone).Error; err != nil {
+		t.Fatal(err)
+	}
+	child.Title = "renamed"
+	if err := tasks.Update(child); err != nil {
+		t.Errorf("editing an open subtask that is already there: %v", err)
+	}
+}
+
+func TestAddDependencyRejectsCycles(t *testing.T) {
+	repo := newTestRepo(t)
+	tasks := service.NewTaskService(repo)
+	a := newTask(t, tasks, "a", models.StatusTodo, nil)
+	b := newTask(t, tasks, "b", models.StatusTodo, nil)
+	c := newTask(t, tasks, "c", models.StatusTodo, nil)
+	d := newTask(t, tasks, "d", models.StatusTodo, nil)
+
+	for _, dep := range [][2]*models.Task{{a, b}, {c, d}, {b, c}} {
+		if _, err := tasks.AddDependency(dep[0].ID, dep[1].ID); err != nil {
+			t.Fatalf("%s -> %s: %v", dep[0].Title, dep[1].Title, err)
+		}
+	}
+	// adding twice is a no-op
+	if _, err := tasks.AddDependency(a.ID, b.ID); err != nil {
+		t.Errorf("repeated a -> b: %v", err)
+	}
+	for _, dep := range [][2]*models.Task{{d, a}, {b, a}, {c, a}, {a, a}} {
+		if _, err := tasks.AddDependency(dep[0].ID, dep[1].ID); !errors.Is(err, service.ErrInvalidRelation) {
+			t.Errorf("%s -> %s = %v, want ErrInvalidRelation", dep[0].Title, dep[1].Title, err)
+		}
+	}
+	if _, err := tasks.AddDependency(a.ID, 9999); !errors.Is(err, service.ErrInvalidRelation) {
+		t.Errorf("dependency on a missing task = %v, want ErrInvalidRelation", err)
+	}
+	if _, err := tasks.AddDependency(9999, a.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("dependency of a missing task = %v, want not found", err)
+	}
+}
@@ -1,34 +1,306 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/Nehyan9895/reminder-system/internal/models"
 	"github.com/Nehyan9895/reminder-system/internal/repository"
 )
 
+var (
+	ErrInvalidStatus     = errors.New("invalid task status")
+	ErrInvalidTransition = errors.New("status transition not allowed")
+	ErrInvalidTag        = errors.New("invalid tag")
+)
+
+// TaskObserver is told about committed task writes, e.g. so the reminder
+// queue can be updated
+type TaskObserver interface {
+	TaskChanged(id uint)
+	TaskDeleted(id uint)
+}
+
+// TaskCompletionObserver is told when a committed write moved a task to done
+type TaskCompletionObserver interface {
+	TaskCompleted(t *models.Task)
+}
+
 type TaskService struct {
-	repo *repository.GormRepo
+	repo        *repository.GormRepo
+	observers   []TaskObserver
+	completions []TaskCompletionObserver
 }
 
 func NewTaskService(repo *repository.GormRepo) *TaskService {
 	return &TaskService{repo: repo}
 }
 
+// AddObserver registers o for task change notifications
+func (s *TaskService) AddObserver(o TaskObserver) {
+	s.observers = append(s.observers, o)
+}
+
+// OnComplete registers o for task completion notifications
+func (s *TaskService) OnComplete(o TaskCompletionObserver) {
+	s.completions = append(s.completions, o)
+}
+
+func (s *TaskService) completed(t *models.Task) {
+	for _, o := range s.completions {
+		o.TaskCompleted(t)
+	}
+}
+
+func (s *TaskService) changed(id uint) {
+	for _, o := range s.observers {
+		o.TaskChanged(id)
+	}
+}
+
+func (s *TaskService) deleted(id uint) {
+	for _, o := range s.observers {
+		o.TaskDeleted(id)
+	}
+}
+
 func (s *TaskService) Create(task *models.Task) error {
-	return s.repo.CreateTask(task)
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	}
+	if !models.ValidStatus(task.Status) {
+		return fmt.Errorf("%w: %s", ErrInvalidStatus, task.Status)
+	}
+	if err := normalizeTags(task); err != nil {
+		return err
+	}
+	if err := s.checkParent(task); err != nil {
+		return err
+	}
+	err := s.withSubtaskLock(task, func(tx *TaskService) error {
+		if err := tx.checkParentOpen(task, nil); err != nil {
+			return err
+		}
+		stampStatus(task, time.Now())
+		return tx.repo.CreateTask(task)
+	})
+	if err != nil {
+		return err
+	}
+	s.changed(task.ID)
+	return nil
 }
 
 func (s *TaskService) Get(id uint) (*models.Task, error) {
 	return s.repo.GetTaskByID(id)
 }
 
-func (s *TaskService) List() ([]models.Task, error) {
-	return s.repo.ListTasks()
+// List returns all tasks, or those carrying every one of tags
+func (s *TaskService) List(tags []string) ([]models.Task, error) {
+	var names []string
+	for _, name := range tags {
+		n, ok := models.NormalizeTagName(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, name)
+		}
+		// "?tag=a&tag=A" asks for one tag, not two
+		if !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	return s.repo.ListTasks(names)
 }
 
+// Update saves task, enforcing the status state machine against the stored row.
+// An empty status keeps the current one and nil tags keep the current tags.
+// The write is conditioned on task.Version and fails with
+// repository.ErrVersionConflict if it is stale.
 func (s *TaskService) Update(task *models.Task) error {
-	return s.repo.UpdateTask(task)
+	current, err := s.repo.GetTaskByID(task.ID)
+	if err != nil {
+		return err
+	}
+	task.CreatedAt = current.CreatedAt
+	task.CompletedAt = current.CompletedAt
+	task.CancelledAt = current.CancelledAt
+	task.SnoozedUntil = current.SnoozedUntil
+	task.BlockedBy = current.BlockedBy
+	if err := s.checkParent(task); err != nil {
+		return err
+	}
+	if task.Tags == nil {
+		task.Tags = current.Tags
+	} else if err := normalizeTags(task); err != nil {
+		return err
+	}
+	if task.Status == "" {
+		task.Status = current.Status
+	}
+	if task.Status != current.Status {
+		if err := checkTransition(current.Status, task.Status); err != nil {
+			return err
+		}
+	}
+	err = s.withSubtaskLock(task, func(tx *TaskService) error {
+		if err := tx.checkParentOpen(task, current); err != nil {
+			return err
+		}
+		if task.Status != current.Status {
+			if err := tx.checkSubtasks(task.ID, task.Status); err != nil {
+				return err
+			}
+			stampStatus(task, time.Now())
+		}
+		return tx.save(task, current.Status, "")
+	})
+	if err != nil {
+		return err
+	}
+	s.changed(task.ID)
+	if task.IsOpen() != current.IsOpen() {
+		s.dependentsChanged(task.ID)
+	}
+	if task.Status == models.StatusDone && current.Status != models.StatusDone {
+		s.completed(task)
+	}
+	return nil
+}
+
+// Transition moves a task to a new status and returns the updated task and its
+// previous status. A non-zero version makes the write conditional on it.
+func (s *TaskService) Transition(id uint, to string, version uint) (*models.Task, string, error) {
+	return s.TransitionAs(id, to, version, "")
+}
+
+// TransitionAs is Transition on behalf of actor, who is recorded with the
+// status change
+func (s *TaskService) TransitionAs(id uint, to string, version uint, actor string) (*models.Task, string, error) {
+	task, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if version != 0 && version != task.Version {
+		return nil, "", repository.ErrVersionConflict
+	}
+	from := task.Status
+	if err := checkTransition(from, to); err != nil {
+		return nil, "", err
+	}
+	next := *task
+	next.Status = to
+	err = s.withSubtaskLock(&next, func(tx *TaskService) error {
+		if err := tx.checkSubtasks(task.ID, to); err != nil {
+			return err
+		}
+		if err := tx.checkParentOpen(&next, task); err != nil {
+			return err
+		}
+		stampStatus(&next, time.Now())
+		return tx.save(&next, from, actor)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	s.changed(next.ID)
+	if next.IsOpen() != task.IsOpen() {
+		s.dependentsChanged(next.ID)
+	}
+	if to == models.StatusDone {
+		s.completed(&next)
+	}
+	return &next, from, nil
+}
+
+// save writes task and, if its status moved away from from, records the
+// change (made by actor) for the task's activity feed in the same transaction
+func (s *TaskService) save(task *models.Task, from, actor string) error {
+	if task.Status == from {
+		return s.repo.UpdateTask(task)
+	}
+	return s.repo.Transaction(func(repo *repository.GormRepo) error {
+		if err := repo.UpdateTask(task); err != nil {
+			return err
+		}
+		return repo.CreateStatusChange(&models.TaskStatusChange{TaskID: task.ID, From: from, To: task.Status, Actor: actor, ChangedAt: task.UpdatedAt})
+	})
+}
+
+// ErrInvalidSnooze is returned for snoozes that do not end in the future
+var ErrInvalidSnooze = errors.New("snooze must end in the future")
+
+// Snooze holds back the task's reminders until the given time; see ruleEval.next
+func (s *TaskService) Snooze(id uint, until time.Time) (*models.Task, error) {
+	if !until.After(time.Now()) {
+		return nil, ErrInvalidSnooze
+	}
+	task, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+	task.SnoozedUntil = &until
+	if err := s.repo.UpdateTask(task); err != nil {
+		return nil, err
+	}
+	s.changed(task.ID)
+	return task, nil
+}
+
+func (s *TaskService) Delete(id, version uint) error {
+	dependents, err := s.repo.DependentTaskIDs(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTask(id, version); err != nil {
+		return err
+	}
+	s.deleted(id)
+	for _, dep := range dependents {
+		s.changed(dep)
+	}
+	return nil
+}
+
+func checkTransition(from, to string) error {
+	if !models.ValidStatus(to) {
+		return fmt.Errorf("%w: %s", ErrInvalidStatus, to)
+	}
+	if !models.CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// normalizeTags normalizes the task's tag names and drops duplicates
+func normalizeTags(task *models.Task) error {
+	if task.Tags == nil {
+		return nil
+	}
+	tags := make([]models.Tag, 0, len(task.Tags))
+	seen := make(map[string]bool, len(task.Tags))
+	for _, tag := range task.Tags {
+		name, ok := models.NormalizeTagName(tag.Name)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag.Name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, models.Tag{Name: name})
+		}
+	}
+	task.Tags = tags
+	return nil
 }
 
-func (s *TaskService) Delete(id uint) error {
-	return s.repo.DeleteTask(id)
+// stampStatus sets or clears the completion timestamps for the task's status
+func stampStatus(task *models.Task, now time.Time) {
+	task.CompletedAt = nil
+	task.CancelledAt = nil
+	switch task.Status {
+	case models.StatusDone:
+		task.CompletedAt = &now
+	case models.StatusCancelled:
+		task.CancelledAt = &now
+	}
 }
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nehyan9895/reminder-system/internal/models"
+	"github.com/Nehyan9895/reminder-system/internal/repository"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// WebhookChannel is the outbox channel webhook deliveries go through
+const WebhookChannel = "webhook"
+
+// Webhook event types
+const (
+	EventReminderTriggered = "reminder.triggered"
+	EventTaskCompleted     = "task.completed"
+	EventRuleCreated       = "rule.created"
+	EventRuleUpdated       = "rule.updated"
+	EventRuleDeleted       = "rule.deleted"
+	EventPing              = "ping" // only sent by the test endpoint
+)
+
+// WebhookEvents lists the event types a webhook can subscribe to
+var WebhookEvents = []string{EventReminderTriggered, EventTaskCompleted, EventRuleCreated, EventRuleUpdated, EventRuleDeleted}
+
+// Webhook request headers
+const (
+	HeaderWebhookID        = "X-Webhook-Id" // same on every retry of an event
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// WebhookEvent is the JSON body POSTed to subscribers
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// ReminderEventData is the data of a reminder.triggered event
+type ReminderEventData struct {
+	Rule        *models.ReminderRule `json:"rule"`
+	Task        *models.Task         `json:"task"`
+	TriggeredAt time.Time            `json:"triggered_at"`
+	Message     string               `json:"message"`
+	Actions     *ReminderLinks       `json:"actions,omitempty"` // signed done/snooze links
+}
+
+type WebhookService struct {
+	repo       *repository.GormRepo
+	dispatcher *Dispatcher
+}
+
+func NewWebhookService(repo *repository.GormRepo, dispatcher *Dispatcher) *WebhookService {
+	return &WebhookService{repo: repo, dispatcher: dispatcher}
+}
+
+// SignWebhook returns the signature of a delivery: hex HMAC-SHA256 over
+// "<timestamp>.<body>", prefixed with the scheme version
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Validate normalizes and checks the URL and event filter of wh
+func (s *WebhookService) Validate(wh *models.Webhook) error {
+	wh.URL = strings.TrimSpace(wh.URL)
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+	types := wh.EventTypes()
+	for _, e := range types {
+		if !slices.Contains(WebhookEvents, e) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, e)
+		}
+	}
+	wh.Events = strings.Join(types, ",")
+	return nil
+}
+
+// Create saves a webhook with a newly generated signing secret
+func (s *WebhookService) Create(wh *models.Webhook) error {
+	if err := s.Validate(wh); err != nil {
+		return err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	wh.ID = 0
+	wh.Secret = "whsec_" + hex.EncodeToString(b)
+	return s.repo.CreateWebhook(wh)
+}
+
+// Publish queues event for every active webhook subscribed to it
+func (s *WebhookService) Publish(event string, data any) {
+	if err := s.publish(s.repo, event, data); err != nil {
+		log.Errorf("[webhooks] queue %s: %v", event, err)
+		return
+	}
+	s.dispatcher.Wake()
+}
+
+// publish writes the outbox rows for event through repo, so callers can make
+// it part of their own transaction
+func (s *WebhookService) publish(repo *repository.GormRepo, event string, data any) error {
+	hooks, err := repo.ActiveWebhooks()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	for i := range hooks {
+		if !hooks[i].Subscribes(event) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(WebhookEvent{ID: NewCorrelationID(), Type: event, CreatedAt: time.Now(), Data: data}); err != nil {
+				return err
+			}
+		}
+		n := &models.Notification{Channel: WebhookChannel, WebhookID: hooks[i].ID, Event: event, Message: string(body)}
+		if d, ok := data.(ReminderEventData); ok {
+			n.RuleID, n.TaskID = d.Rule.ID, d.Task.ID
+		}
+		if err := repo.CreateNotification(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping queues a ping event for one webhook, regardless of its filter
+func (s *WebhookService) Ping(wh *models.Webhook) (*models.Notification, error) {
+	body, err := json.Marshal(WebhookEvent{
+		ID:        NewCorrelationID(),
+		Type:      EventPing,
+		CreatedAt: time.Now(),
+		Data:      map[string]any{"webhook_id": wh.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	n := &models.Notification{Channel: WebhookChannel, WebhookID: wh.ID, Event: EventPing, Message: string(body)}
+	if err := s.repo.CreateNotification(n); err != nil {
+		return nil, err
+	}
+	s.dispatcher.Wake()
+	return n, nil
+}
+
+// TaskCompleted publishes task.completed
+func (s *WebhookService) TaskCompleted(t *models.Task) {
+	s.Publish(EventTaskCompleted, t)
+}
+
+// WebhookNotifier POSTs signed webhook events
+type WebhookNotifier struct {
+	repo   *repository.GormRepo
+	client *http.Client
+}
+
+func NewWebhookNotifier(repo *repository.GormRepo) *WebhookNotifier {
+	return &WebhookNotifier{repo: repo, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (*WebhookNotifier) Channel() string { return WebhookChannel }
+
+// Notify delivers n to its webhook; any non-2xx response is a failure
+func (wn *WebhookNotifier) Notify(ctx context.Context, n *models.Notification) error {
+	wh, err := wn.repo.GetWebhookByID(n.WebhookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("webhook %d was deleted: %w", n.WebhookID, ErrUndeliverable)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook %d: %w", n.WebhookID, err)
+	}
+	var ev struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal([]byte(n.Message), &ev)
+
+	body := []byte(n.Message)
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "reminder-system-webhooks/1")
+	req.Header.Set(HeaderWebhookID, ev.ID)
+	req.Header.Set(HeaderWebhookEvent, n.Event)
+	req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(wh.Secret, ts, body))
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
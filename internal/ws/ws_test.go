@@ -0,0 +1,404 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is the raw client side of a connection accepted by Upgrade
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial starts a server that upgrades one request and returns both ends
+func dial(t *testing.T) (*Conn, *testClient) {
+	t.Helper()
+	accepted := make(chan *Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- c
+	}))
+	t.Cleanup(srv.Close)
+
+	nc, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nc.Close() })
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err := nc.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status %d", resp.StatusCode)
+	}
+	// the example from RFC 6455 section 1.3
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	select {
+	case c := <-accepted:
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return c, &testClient{t: t, conn: nc, br: br}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+	return nil, nil
+}
+
+// send writes one client frame; masked frames use a fixed key
+func (tc *testClient) send(fin bool, opcode byte, payload []byte, masked bool) {
+	tc.t.Helper()
+	var b0 byte = opcode
+	if fin {
+		b0 |= 0x80
+	}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	hdr := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		hdr = append(hdr, maskBit|byte(n))
+	case n <= 0xFFFF:
+		hdr = binary.BigEndian.AppendUint16(append(hdr, maskBit|126), uint16(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64(append(hdr, maskBit|127), uint64(n))
+	}
+	body := append([]byte(nil), payload...)
+	if masked {
+		mask := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+		hdr = append(hdr, mask[:]...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+	if _, err := tc.conn.Write(append(hdr, body...)); err != nil {
+		tc.t.Fatal(err)
+	}
+}
+
+// recv reads one server frame, which must be unmasked
+func (tc *testClient) recv() frame {
+	tc.t.Helper()
+	var hdr [2]byte
+	if _, err := io.ReadFull(tc.br, hdr[:]); err != nil {
+		tc.t.Fatalf("read frame: %v", err)
+	}
+	if hdr[1]&0x80 != 0 {
+		tc.t.Fatal("server frame is masked")
+	}
+	n := int(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(tc.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(tc.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	f := frame{fin: hdr[0]&0x80 != 0, opcode: hdr[0] & 0x0F, payload: make([]byte, n)}
+	if _, err := io.ReadFull(tc.br, f.payload); err != nil {
+		tc.t.Fatalf("read payload: %v", err)
+	}
+	return f
+}
+
+// expectClose reads a close frame with code and then the end of the connection
+func (tc *testClient) expectClose(code int) {
+	tc.t.Helper()
+	f := tc.recv()
+	if f.opcode != opClose || len(f.payload) < 2 {
+		tc.t.Fatalf("got opcode %#x %q, want a close frame", f.opcode, f.payload)
+	}
+	if got := int(binary.BigEndian.Uint16(f.payload)); got != code {
+		tc.t.Errorf("close code %d (%s), want %d", got, f.payload[2:], code)
+	}
+	if _, err := tc.br.ReadByte(); err != io.EOF {
+		tc.t.Errorf("connection still open after close: %v", err)
+	}
+}
+
+func closeCode(t *testing.T, err error) int {
+	t.Helper()
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		t.Fatalf("got %v, want a *CloseError", err)
+	}
+	return ce.Code
+}
+
+func TestReadMaskedMessages(t *testing.T) {
+	server, client := dial(t)
+	server.MaxMessageSize = 1 << 20
+	// every payload length encoding: 7-bit, 16-bit and 64-bit
+	for _, size := range []int{0, 5, 125, 126, 1000, 0xFFFF + 1} {
+		client.send(true, OpText, bytes.Repeat([]byte("x"), size), true)
+		op, data, err := server.ReadMessage()
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if op != OpText || len(data) != size || strings.Trim(string(data), "x") != "" {
+			t.Fatalf("size %d: got opcode %d and %d bytes", size, op, len(data))
+		}
+	}
+}
+
+func TestRejectUnmaskedFrame(t *testing.T) {
+	server, client := dial(t)
+	client.send(true, OpText, []byte("hello"), false)
+	_, _, err := server.ReadMessage()
+	if code := closeCode(t, err); code != CloseProtocolError {
+		t.Errorf("close code %d, want %d", code, CloseProtocolError)
+	}
+	client.expectClose(CloseProtocolError)
+}
+
+func TestWriteUnmaskedFrames(t *testing.T) {
+	server, client := dial(t)
+	for _, size := range []int{3, 300, 0xFFFF + 1} {
+		msg := bytes.Repeat([]byte("y"), size)
+		go server.WriteText(msg)
+		f := client.recv()
+		if !f.fin || f.opcode != OpText || !bytes.Equal(f.payload, msg) {
+			t.Fatalf("size %d: got fin %v opcode %d and %d bytes", size, f.fin, f.opcode, len(f.payload))
+		}
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	server, client := dial(t)
+	client.send(false, OpText, []byte("hel"), true)
+	client.send(false, opContinuation, []byte("lo "), true)
+	client.send(true, opContinuation, []byte("world"), true)
+	op, data, err := server.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != OpText || string(data) != "hello world" {
+		t.Errorf("got %d %q", op, data)
+	}
+}
+
+func TestPingBetweenFragments(t *testing.T) {
+	server, client := dial(t)
+	client.send(false, OpBinary, []byte{1, 2}, true)
+	client.send(true, opPing, []byte("are you there"), true)
+	client.send(true, opContinuation, []byte{3}, true)
+
+	type result struct {
+		op   byte
+		data []byte
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		op, data, err := server.ReadMessage()
+		got <- result{op, data, err}
+	}()
+	pong := client.recv()
+	if pong.opcode != opPong || string(pong.payload) != "are you there" {
+		t.Errorf("got opcode %#x %q, want the ping echoed in a pong", pong.opcode, pong.payload)
+	}
+	r := <-got
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.op != OpBinary || !bytes.Equal(r.data, []byte{1, 2, 3}) {
+		t.Errorf("got %d %v", r.op, r.data)
+	}
+}
+
+func TestPongHandler(t *testing.T) {
+	server, client := dial(t)
+	pongs := 0
+	server.PongHandler = func() { pongs++ }
+	client.send(true, opPong, nil, true)
+	client.send(true, OpText, []byte("after"), true)
+	if _, data, err := server.ReadMessage(); err != nil || string(data) != "after" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if pongs != 1 {
+		t.Errorf("PongHandler called %d times", pongs)
+	}
+}
+
+func TestFragmentErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames func(c *testClient)
+		code   int
+	}{
+		{"continuation without start", func(c *testClient) {
+			c.send(true, opContinuation, []byte("x"), true)
+		}, CloseProtocolError},
+		{"new message inside fragment", func(c *testClient) {
+			c.send(false, OpText, []byte("a"), true)
+			c.send(true, OpText, []byte("b"), true)
+		}, CloseProtocolError},
+		{"fragmented control frame", func(c *testClient) {
+			c.send(false, opPing, nil, true)
+		}, CloseProtocolError},
+		{"control frame too long", func(c *testClient) {
+			c.send(true, opPing, bytes.Repeat([]byte("p"), 126), true)
+		}, CloseProtocolError},
+		{"reserved bits", func(c *testClient) {
+			c.conn.Write([]byte{0x80 | 0x40 | OpText, 0x80, 0, 0, 0, 0})
+		}, CloseProtocolError},
+		{"unknown opcode", func(c *testClient) {
+			c.send(true, 0x3, nil, true)
+		}, CloseProtocolError},
+		{"invalid utf-8", func(c *testClient) {
+			c.send(false, OpText, []byte{0xe2, 0x82}, true)
+			c.send(true, opContinuation, []byte{0x28}, true)
+		}, CloseInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := dial(t)
+			tt.frames(client)
+			_, _, err := server.ReadMessage()
+			if code := closeCode(t, err); code != tt.code {
+				t.Errorf("close code %d, want %d", code, tt.code)
+			}
+			client.expectClose(tt.code)
+		})
+	}
+}
+
+func TestOversizeMessage(t *testing.T) {
+	t.Run("single frame", func(t *testing.T) {
+		server, client := dial(t)
+		server.MaxMessageSize = 10
+		client.send(true, OpText, bytes.Repeat([]byte("x"), 11), true)
+		_, _, err := server.ReadMessage()
+		if code := closeCode(t, err); code != CloseTooBig {
+			t.Errorf("close code %d, want %d", code, CloseTooBig)
+		}
+		client.expectClose(CloseTooBig)
+	})
+	t.Run("fragments", func(t *testing.T) {
+		server, client := dial(t)
+		server.MaxMessageSize = 10
+		client.send(false, OpText, bytes.Repeat([]byte("x"), 6), true)
+		client.send(true, opContinuation, bytes.Repeat([]byte("x"), 5), true)
+		_, _, err := server.ReadMessage()
+		if code := closeCode(t, err); code != CloseTooBig {
+			t.Errorf("close code %d, want %d", code, CloseTooBig)
+		}
+		client.expectClose(CloseTooBig)
+	})
+	t.Run("exactly the limit", func(t *testing.T) {
+		server, client := dial(t)
+		server.MaxMessageSize = 10
+		client.send(false, OpText, bytes.Repeat([]byte("x"), 6), true)
+		client.send(true, opContinuation, bytes.Repeat([]byte("x"), 4), true)
+		if _, data, err := server.ReadMessage(); err != nil || len(data) != 10 {
+			t.Fatalf("got %d bytes, %v", len(data), err)
+		}
+	})
+}
+
+func TestCloseHandshake(t *testing.T) {
+	t.Run("client initiated", func(t *testing.T) {
+		server, client := dial(t)
+		client.send(true, opClose, append(binary.BigEndian.AppendUint16(nil, CloseGoingAway), "bye"...), true)
+		_, _, err := server.ReadMessage()
+		var ce *CloseError
+		if !errors.As(err, &ce) || ce.Code != CloseGoingAway || ce.Reason != "bye" {
+			t.Fatalf("got %v, want close 1001 bye", err)
+		}
+		client.expectClose(CloseNormal)
+		if err := server.WriteText([]byte("late")); err != ErrClosed {
+			t.Errorf("write after close = %v, want ErrClosed", err)
+		}
+	})
+	t.Run("no status code", func(t *testing.T) {
+		server, client := dial(t)
+		client.send(true, opClose, nil, true)
+		_, _, err := server.ReadMessage()
+		if code := closeCode(t, err); code != 1005 {
+			t.Errorf("close code %d, want 1005", code)
+		}
+		client.expectClose(CloseNormal)
+	})
+	t.Run("server initiated", func(t *testing.T) {
+		server, client := dial(t)
+		if err := server.WriteClose(ClosePolicyViolation, "session revoked"); err != nil {
+			t.Fatal(err)
+		}
+		f := client.recv()
+		if f.opcode != opClose || binary.BigEndian.Uint16(f.payload) != ClosePolicyViolation || string(f.payload[2:]) != "session revoked" {
+			t.Fatalf("got opcode %#x %q", f.opcode, f.payload)
+		}
+		if err := server.WriteText([]byte("late")); err != ErrClosed {
+			t.Errorf("write after close = %v, want ErrClosed", err)
+		}
+		// the peer's reply ends the read loop
+		client.send(true, opClose, binary.BigEndian.AppendUint16(nil, ClosePolicyViolation), true)
+		_, _, err := server.ReadMessage()
+		if code := closeCode(t, err); code != ClosePolicyViolation {
+			t.Errorf("close code %d, want %d", code, ClosePolicyViolation)
+		}
+	})
+}
+
+func TestUpgradeRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{"not GET", http.MethodPost, nil, http.StatusMethodNotAllowed},
+		{"no upgrade", http.MethodGet, map[string]string{"Upgrade": ""}, http.StatusUpgradeRequired},
+		{"old version", http.MethodGet, map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"bad key", http.MethodGet, map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header.Set("Connection", "keep-alive, Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Sec-WebSocket-Version", "13")
+			r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			if _, err := Upgrade(w, r); err == nil {
+				t.Fatal("Upgrade succeeded")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}